@@ -0,0 +1,214 @@
+// Package admin provides a http handler to inspect and revoke the sessions
+// (e.g. by on-call engineers). It can destroy any session, so it must be
+// protected, like behind an authentication middleware.
+//
+// The session identifiers are never shown, only their redacted form (see
+// session.RedactID()), which is accepted wherever an identifier is.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	sessionpkg "github.com/xandalm/go-session"
+)
+
+// Shown in place of a redacted value.
+const Redacted = "[redacted]"
+
+// Redactor returns the value to be shown for the session key.
+type Redactor func(key string, value any) any
+
+// Redacts all the values, which is the default Redactor.
+func RedactAll(key string, value any) any {
+	return Redacted
+}
+
+// Returns a Redactor that shows the values of the given keys, redacting
+// the other ones.
+func ShowValues(keys ...string) Redactor {
+	return func(key string, value any) any {
+		if slices.Contains(keys, key) {
+			return value
+		}
+		return Redacted
+	}
+}
+
+// Handler serves the admin routes, which respond with JSON:
+//
+//	GET    /sessions[?uid=]       lists the sessions, optionally of the user
+//	GET    /sessions/{id}         shows the session, with its keys
+//	DELETE /sessions/{id}         revokes the session
+//	DELETE /users/{uid}/sessions  revokes the user sessions
+//
+// To serve it under a path, strip it:
+//
+//	http.Handle("/admin/", http.StripPrefix("/admin", handler))
+type Handler struct {
+	storage sessionpkg.Storage
+	manager *sessionpkg.Manager
+	mu      sync.RWMutex
+	redact  Redactor
+	mux     *http.ServeMux
+}
+
+// Returns a new Handler, listing the sessions through the storage and
+// revoking them through the manager, so its hooks are called.
+//
+// Panics if any of them is nil.
+func NewHandler(storage sessionpkg.Storage, manager *sessionpkg.Manager) *Handler {
+	if storage == nil {
+		panic("nil storage")
+	}
+	if manager == nil {
+		panic("nil manager")
+	}
+	h := &Handler{
+		storage: storage,
+		manager: manager,
+		redact:  RedactAll,
+		mux:     http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET /sessions", h.list)
+	h.mux.HandleFunc("GET /sessions/{id}", h.show)
+	h.mux.HandleFunc("DELETE /sessions/{id}", h.revoke)
+	h.mux.HandleFunc("DELETE /users/{uid}/sessions", h.revokeUser)
+	return h
+}
+
+// Sets how the session values are shown. Setting nil restores RedactAll,
+// which is the default.
+func (h *Handler) SetRedactor(fn Redactor) {
+	if fn == nil {
+		fn = RedactAll
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.redact = fn
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+type sessionJSON struct {
+	ID       string         `json:"id"`
+	Created  time.Time      `json:"created"`
+	Accessed time.Time      `json:"accessed"`
+	UID      string         `json:"uid,omitempty"`
+	Keys     []string       `json:"keys,omitempty"`
+	Values   map[string]any `json:"values,omitempty"`
+}
+
+func newSessionJSON(info sessionpkg.SessionInfo) sessionJSON {
+	return sessionJSON{
+		ID:       sessionpkg.RedactID(info.ID),
+		Created:  info.Created,
+		Accessed: info.Accessed,
+		UID:      info.UID,
+	}
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	infos, err := h.storage.ListSessions()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	uid := r.URL.Query().Get("uid")
+	sessions := []sessionJSON{}
+	for _, info := range infos {
+		if uid == "" || info.UID == uid {
+			sessions = append(sessions, newSessionJSON(info))
+		}
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (h *Handler) show(w http.ResponseWriter, r *http.Request) {
+	info, err := h.find(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	sess, err := h.storage.GetSession(info.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if sess == nil {
+		writeError(w, sessionpkg.ErrUnknownSessionId)
+		return
+	}
+	s := newSessionJSON(info)
+	if kl, ok := sess.(sessionpkg.KeyLister); ok {
+		h.mu.RLock()
+		redact := h.redact
+		h.mu.RUnlock()
+		s.Keys = kl.Keys()
+		s.Values = map[string]any{}
+		for _, key := range s.Keys {
+			s.Values[key] = redact(key, sess.Get(key))
+		}
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+func (h *Handler) revoke(w http.ResponseWriter, r *http.Request) {
+	info, err := h.find(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.manager.RevokeSession(info.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) revokeUser(w http.ResponseWriter, r *http.Request) {
+	revoked, err := h.manager.RevokeUserSessions(r.PathValue("uid"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"revoked": revoked})
+}
+
+// Returns the session info by its identifier or its redacted form, or
+// ErrUnknownSessionId if there's none.
+func (h *Handler) find(id string) (sessionpkg.SessionInfo, error) {
+	infos, err := h.storage.ListSessions()
+	if err != nil {
+		return sessionpkg.SessionInfo{}, err
+	}
+	for _, info := range infos {
+		if info.ID == id || sessionpkg.RedactID(info.ID) == id {
+			return info, nil
+		}
+	}
+	return sessionpkg.SessionInfo{}, sessionpkg.ErrUnknownSessionId
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, sessionpkg.ErrUnknownSessionId):
+		code = http.StatusNotFound
+	case errors.Is(err, sessionpkg.ErrNotSupported):
+		code = http.StatusNotImplemented
+	}
+	http.Error(w, http.StatusText(code), code)
+}
@@ -0,0 +1,111 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sessionpkg "github.com/xandalm/go-session"
+	"github.com/xandalm/go-session/cookie"
+	"github.com/xandalm/go-session/memory"
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res
+}
+
+func decode[T any](t *testing.T, res *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		t.Fatalf("didn't respond with json, %v", err)
+	}
+	return v
+}
+
+func TestHandler(t *testing.T) {
+	storage := memory.Storage()
+	manager := sessionpkg.NewManager(sessionpkg.NewProvider(storage, nil), "SessionID", 3600)
+	handler := NewHandler(storage, manager)
+
+	startSession := func() sessionpkg.Session {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		return manager.StartSession(httptest.NewRecorder(), req)
+	}
+	alex := startSession()
+	alex.Set("name", "Alex")
+	alex.Set("token", "secret")
+	assert.NoError(t, manager.BindUser(alex, "alex"))
+	other := startSession()
+
+	t.Run("lists the sessions", func(t *testing.T) {
+		res := serve(handler, http.MethodGet, "/sessions")
+
+		assert.Equal(t, res.Code, http.StatusOK)
+		body := res.Body.String()
+		got := decode[[]sessionJSON](t, res)
+		assert.Equal(t, len(got), 2)
+		assert.Equal(t, got[0].ID, sessionpkg.RedactID(alex.SessionID()))
+		assert.Equal(t, got[0].UID, "alex")
+		assert.Equal(t, got[1].ID, sessionpkg.RedactID(other.SessionID()))
+		if strings.Contains(body, alex.SessionID()) {
+			t.Error("didn't redact the session id")
+		}
+	})
+	t.Run("lists the user sessions", func(t *testing.T) {
+		res := serve(handler, http.MethodGet, "/sessions?uid=alex")
+
+		got := decode[[]sessionJSON](t, res)
+		assert.Equal(t, len(got), 1)
+		assert.Equal(t, got[0].UID, "alex")
+	})
+	t.Run("shows the session with redacted values", func(t *testing.T) {
+		handler.SetRedactor(ShowValues("name"))
+		defer handler.SetRedactor(nil)
+
+		res := serve(handler, http.MethodGet, "/sessions/"+sessionpkg.RedactID(alex.SessionID()))
+
+		assert.Equal(t, res.Code, http.StatusOK)
+		got := decode[sessionJSON](t, res)
+		assert.Equal(t, strings.Join(got.Keys, ","), "name,token")
+		assert.Equal(t, got.Values["name"], any("Alex"))
+		assert.Equal(t, got.Values["token"], any(Redacted))
+	})
+	t.Run("responds not found for unknown session", func(t *testing.T) {
+		res := serve(handler, http.MethodGet, "/sessions/abcde")
+
+		assert.Equal(t, res.Code, http.StatusNotFound)
+	})
+	t.Run("revokes the session", func(t *testing.T) {
+		res := serve(handler, http.MethodDelete, "/sessions/"+sessionpkg.RedactID(other.SessionID()))
+
+		assert.Equal(t, res.Code, http.StatusNoContent)
+		if ok, _ := storage.ContainsSession(other.SessionID()); ok {
+			t.Error("didn't revoke the session")
+		}
+	})
+	t.Run("revokes the user sessions", func(t *testing.T) {
+		res := serve(handler, http.MethodDelete, "/users/alex/sessions")
+
+		assert.Equal(t, res.Code, http.StatusOK)
+		got := decode[map[string]int](t, res)
+		assert.Equal(t, got["revoked"], 1)
+		if ok, _ := storage.ContainsSession(alex.SessionID()); ok {
+			t.Error("didn't revoke the user session")
+		}
+	})
+	t.Run("responds not implemented when the storage cannot list", func(t *testing.T) {
+		storage, _ := cookie.NewStorage("SessionData", make([]byte, 32))
+		manager := sessionpkg.NewManager(sessionpkg.NewProvider(storage, nil), "SessionID", 3600)
+
+		res := serve(NewHandler(storage, manager), http.MethodGet, "/sessions")
+
+		assert.Equal(t, res.Code, http.StatusNotImplemented)
+	})
+}
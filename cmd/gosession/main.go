@@ -0,0 +1,211 @@
+// Command gosession inspects and maintains the sessions directory of the
+// filesystem storage, while the application isn't running.
+//
+// Usage:
+//
+//	gosession [-dir path] <command> [arguments]
+//
+// The commands are:
+//
+//	list                          lists the sessions, oldest first
+//	dump <id>                     prints the session as JSON
+//	delete <id>...                deletes the sessions
+//	gc -max-age d [-idle d]       deletes the expired sessions
+//	verify                        reports the corrupted session files
+//
+// The directory defaults to the one used by filesystem.Storage().
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	sessionpkg "github.com/xandalm/go-session"
+	"github.com/xandalm/go-session/filesystem"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Storage operations used by the commands.
+type storage interface {
+	sessionpkg.Storage
+	Verify() (map[string]error, error)
+}
+
+// Runs the command line, returning the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gosession", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", filepath.Join(os.TempDir(), "gosessions"), "sessions directory")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gosession [-dir path] list | dump <id> | delete <id>... | gc -max-age d [-idle d] | verify")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if _, err := os.Stat(*dir); err != nil {
+		fmt.Fprintln(stderr, "gosession:", err)
+		return 1
+	}
+	s := filesystem.Storage(*dir)
+
+	var err error
+	switch cmd, args := fs.Arg(0), fs.Args()[1:]; cmd {
+	case "list":
+		err = list(s, stdout)
+	case "dump":
+		if len(args) != 1 {
+			fs.Usage()
+			return 2
+		}
+		err = dump(s, args[0], stdout)
+	case "delete":
+		if len(args) == 0 {
+			fs.Usage()
+			return 2
+		}
+		err = remove(s, args, stdout)
+	case "gc":
+		err = gc(s, args, stdout, stderr)
+	case "verify":
+		err = verify(s, stdout)
+	default:
+		fmt.Fprintf(stderr, "gosession: unknown command %q\n", cmd)
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "gosession:", err)
+		return 1
+	}
+	return 0
+}
+
+func list(s storage, w io.Writer) error {
+	infos, err := s.ListSessions()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCREATED\tACCESSED\tUSER")
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", info.ID, info.Created.Format(time.RFC3339), info.Accessed.Format(time.RFC3339), info.UID)
+	}
+	return tw.Flush()
+}
+
+type sessionJSON struct {
+	ID       string         `json:"id"`
+	Created  time.Time      `json:"created"`
+	Accessed time.Time      `json:"accessed"`
+	UID      string         `json:"uid,omitempty"`
+	Values   map[string]any `json:"values"`
+}
+
+func dump(s storage, sid string, w io.Writer) error {
+	infos, err := s.ListSessions()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(infos, func(info sessionpkg.SessionInfo) bool {
+		return info.ID == sid
+	})
+	if i < 0 {
+		return fmt.Errorf("%w: %s", sessionpkg.ErrUnknownSessionId, sid)
+	}
+	sess, err := s.GetSession(sid)
+	if err != nil {
+		return err
+	}
+	out := sessionJSON{
+		ID:       sid,
+		Created:  infos[i].Created,
+		Accessed: infos[i].Accessed,
+		UID:      infos[i].UID,
+		Values:   map[string]any{},
+	}
+	for _, key := range sess.(sessionpkg.KeyLister).Keys() {
+		out.Values[key] = sess.Get(key)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func remove(s storage, sids []string, w io.Writer) error {
+	var errs []error
+	for _, sid := range sids {
+		if ok, _ := s.ContainsSession(sid); !ok {
+			errs = append(errs, fmt.Errorf("%w: %s", sessionpkg.ErrUnknownSessionId, sid))
+			continue
+		}
+		if err := s.ReapSession(sid); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Fprintln(w, "deleted", sid)
+	}
+	return errors.Join(errs...)
+}
+
+// Checks the age since the time, like the provider does with the
+// sessions lifetime.
+type ageChecker time.Duration
+
+func (d ageChecker) ShouldReap(t time.Time) bool {
+	return time.Since(t) >= time.Duration(d)
+}
+
+func gc(s storage, args []string, w, stderr io.Writer) error {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	maxAge := fs.Duration("max-age", 0, "lifetime since the session creation (required)")
+	idle := fs.Duration("idle", 0, "lifetime since the session last access, zero disables it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *maxAge <= 0 {
+		return errors.New("gc: -max-age must be positive")
+	}
+	var idleChecker sessionpkg.AgeChecker
+	if *idle > 0 {
+		idleChecker = ageChecker(*idle)
+	}
+	reaped, err := s.Deadline(ageChecker(*maxAge), idleChecker)
+	for _, e := range reaped {
+		fmt.Fprintln(w, "deleted", e.ID)
+	}
+	fmt.Fprintf(w, "%d expired sessions deleted\n", len(reaped))
+	return err
+}
+
+func verify(s storage, w io.Writer) error {
+	errs, err := s.Verify()
+	if err != nil {
+		return err
+	}
+	for _, sid := range slices.Sorted(maps.Keys(errs)) {
+		fmt.Fprintf(w, "corrupted %s: %v\n", sid, errs[sid])
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d corrupted session files", len(errs))
+	}
+	fmt.Fprintln(w, "ok")
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xandalm/go-session/filesystem"
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func runCommand(t *testing.T, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	var out, errOut strings.Builder
+	code = run(args, &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	storage := filesystem.Storage(dir)
+	sess, _ := storage.CreateSession("abcde")
+	sess.Set("foo", "bar")
+	storage.CreateSession("fghij")
+
+	t.Run("lists the sessions", func(t *testing.T) {
+		code, out, _ := runCommand(t, "-dir", dir, "list")
+
+		assert.Equal(t, code, 0)
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		assert.Equal(t, len(lines), 3)
+		if !strings.HasPrefix(lines[1], "abcde ") || !strings.HasPrefix(lines[2], "fghij ") {
+			t.Errorf("didn't list the sessions, got:\n%s", out)
+		}
+	})
+	t.Run("dumps the session", func(t *testing.T) {
+		code, out, _ := runCommand(t, "-dir", dir, "dump", "abcde")
+
+		assert.Equal(t, code, 0)
+		var got sessionJSON
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("didn't dump json, %v", err)
+		}
+		assert.Equal(t, got.ID, "abcde")
+		assert.Equal(t, got.Values["foo"], any("bar"))
+	})
+	t.Run("fails to dump unknown session", func(t *testing.T) {
+		code, _, errOut := runCommand(t, "-dir", dir, "dump", "xyz")
+
+		assert.Equal(t, code, 1)
+		if !strings.Contains(errOut, "unknown sid") {
+			t.Errorf("didn't report unknown session, got %q", errOut)
+		}
+	})
+	t.Run("verifies the session files", func(t *testing.T) {
+		code, out, _ := runCommand(t, "-dir", dir, "verify")
+		assert.Equal(t, code, 0)
+		assert.Equal(t, out, "ok\n")
+
+		os.WriteFile(filepath.Join(dir, "gosess_klmno"), []byte("corrupted"), 0666)
+		defer os.Remove(filepath.Join(dir, "gosess_klmno"))
+
+		code, out, _ = runCommand(t, "-dir", dir, "verify")
+		assert.Equal(t, code, 1)
+		if !strings.HasPrefix(out, "corrupted klmno: ") {
+			t.Errorf("didn't report the corrupted file, got %q", out)
+		}
+	})
+	t.Run("deletes the session", func(t *testing.T) {
+		code, out, _ := runCommand(t, "-dir", dir, "delete", "fghij")
+
+		assert.Equal(t, code, 0)
+		assert.Equal(t, out, "deleted fghij\n")
+		if _, err := os.Stat(filepath.Join(dir, "gosess_fghij")); !os.IsNotExist(err) {
+			t.Error("didn't delete the session file")
+		}
+	})
+	t.Run("deletes the expired sessions", func(t *testing.T) {
+		code, out, _ := runCommand(t, "-dir", dir, "gc", "-max-age", "1h")
+		assert.Equal(t, code, 0)
+		assert.Equal(t, out, "0 expired sessions deleted\n")
+
+		time.Sleep(10 * time.Millisecond)
+		code, out, _ = runCommand(t, "-dir", dir, "gc", "-max-age", "5ms")
+		assert.Equal(t, code, 0)
+		assert.Equal(t, out, "deleted abcde\n1 expired sessions deleted\n")
+	})
+	t.Run("requires the max age to gc", func(t *testing.T) {
+		code, _, _ := runCommand(t, "-dir", dir, "gc")
+
+		assert.Equal(t, code, 1)
+	})
+	t.Run("fails for unknown command", func(t *testing.T) {
+		code, _, _ := runCommand(t, "-dir", dir, "foo")
+
+		assert.Equal(t, code, 2)
+	})
+	t.Run("doesn't create the default directory", func(t *testing.T) {
+		tmp := t.TempDir()
+		t.Setenv("TMPDIR", tmp)
+
+		runCommand(t, "-dir", dir, "list")
+
+		if _, err := os.Stat(filepath.Join(tmp, "gosessions")); !os.IsNotExist(err) {
+			t.Errorf("didn't expect the default directory, got %v", err)
+		}
+	})
+	t.Run("fails for missing directory", func(t *testing.T) {
+		code, _, _ := runCommand(t, "-dir", filepath.Join(dir, "missing"), "list")
+
+		assert.Equal(t, code, 1)
+	})
+}
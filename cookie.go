@@ -0,0 +1,110 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	securePrefix = "__Secure-"
+	hostPrefix   = "__Host-"
+)
+
+// CookieOptions holds the attributes of the session http cookie.
+type CookieOptions struct {
+	Path        string
+	Domain      string
+	Secure      bool
+	HttpOnly    bool
+	SameSite    http.SameSite
+	Partitioned bool
+}
+
+// Returns the options used by the Manager when none is set, which
+// is a http only cookie for the root path.
+func DefaultCookieOptions() CookieOptions {
+	return CookieOptions{
+		Path:     "/",
+		HttpOnly: true,
+	}
+}
+
+var ErrInvalidCookieOptions error = errors.New("session: invalid cookie options")
+
+// Checks if the options can be used for a cookie with the given name.
+//
+// Returns error when:
+// - The path doesn't start with "/";
+// - The name has "__Secure-" prefix and the cookie isn't secure;
+// - The name has "__Host-" prefix and the cookie isn't secure, has
+// a domain or the path isn't "/";
+// - SameSite is None or Partitioned is set and the cookie isn't secure.
+func (o CookieOptions) Validate(name string) error {
+	if o.Path != "" && !strings.HasPrefix(o.Path, "/") {
+		return fmt.Errorf("%w: path must start with \"/\"", ErrInvalidCookieOptions)
+	}
+	if strings.HasPrefix(name, securePrefix) && !o.Secure {
+		return fmt.Errorf("%w: %s prefixed cookie must be secure", ErrInvalidCookieOptions, securePrefix)
+	}
+	if strings.HasPrefix(name, hostPrefix) {
+		if !o.Secure {
+			return fmt.Errorf("%w: %s prefixed cookie must be secure", ErrInvalidCookieOptions, hostPrefix)
+		}
+		if o.Domain != "" {
+			return fmt.Errorf("%w: %s prefixed cookie cannot have domain", ErrInvalidCookieOptions, hostPrefix)
+		}
+		if o.Path != "" && o.Path != "/" {
+			return fmt.Errorf("%w: %s prefixed cookie must have path \"/\"", ErrInvalidCookieOptions, hostPrefix)
+		}
+	}
+	if o.SameSite == http.SameSiteNoneMode && !o.Secure {
+		return fmt.Errorf("%w: SameSite=None cookie must be secure", ErrInvalidCookieOptions)
+	}
+	if o.Partitioned && !o.Secure {
+		return fmt.Errorf("%w: partitioned cookie must be secure", ErrInvalidCookieOptions)
+	}
+	return nil
+}
+
+func (o CookieOptions) cookie(name, value string, maxAge int) *http.Cookie {
+	path := o.Path
+	if path == "" {
+		path = "/"
+	}
+	c := &http.Cookie{
+		Name:        name,
+		Value:       value,
+		Path:        path,
+		Domain:      o.Domain,
+		Secure:      o.Secure,
+		HttpOnly:    o.HttpOnly,
+		SameSite:    o.SameSite,
+		Partitioned: o.Partitioned,
+		MaxAge:      maxAge,
+	}
+	if maxAge < 0 {
+		c.Expires = time.Now()
+	}
+	return c
+}
+
+func defaultCookieOptionsFor(name string) CookieOptions {
+	opts := DefaultCookieOptions()
+	if strings.HasPrefix(name, securePrefix) || strings.HasPrefix(name, hostPrefix) {
+		opts.Secure = true
+	}
+	return opts
+}
+
+// Sets the attributes for the session http cookie, which are used
+// both to start and destroy the session. They only apply to the cookie
+// transport created by NewManager().
+//
+// Returns error if the options cannot be used with the manager cookie
+// name (see CookieOptions.Validate()).
+func (m *Manager) SetCookieOptions(opts CookieOptions) error {
+	return m.cookie.SetCookieOptions(opts)
+}
@@ -0,0 +1,538 @@
+package cookie
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	sessionpkg "github.com/xandalm/go-session"
+)
+
+const (
+	chunkSize = 3800 // cookie value max length, under browsers ~4KB limit
+	maxChunks = 10
+)
+
+var (
+	ErrNoKeys          error = errors.New("cookie: at least one key is required")
+	ErrPayloadTooLarge error = errors.New("cookie: session payload exceeds the cookies limit")
+	ErrInvalidPayload  error = errors.New("cookie: invalid session payload")
+)
+
+type extSession struct {
+	Id     string
+	V      map[string]any
+	Ct, At int64
+}
+
+type session struct {
+	mu     sync.Mutex
+	id     string
+	v      map[string]any
+	ct     time.Time
+	at     time.Time
+	chunks int  // number of cookies holding the payload
+	dirty  bool // must be written into the response
+	reaped bool // must be removed from the client
+	s      *storage
+}
+
+func newSession(s *storage, sid string) *session {
+	now := time.Now()
+	return &session{
+		id:    sid,
+		v:     map[string]any{},
+		ct:    now,
+		at:    now,
+		dirty: true,
+		s:     s,
+	}
+}
+
+func (s *session) SessionID() string {
+	return s.id
+}
+
+func (s *session) CreationTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ct
+}
+
+func (s *session) AccessTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.at
+}
+
+func (s *session) Get(key string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.v[key]
+}
+
+func (s *session) Set(key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.v[key] = value
+	s.at = time.Now()
+	s.dirty = true
+	return nil
+}
+
+func (s *session) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.v, key)
+	s.at = time.Now()
+	s.dirty = true
+	return nil
+}
+
+// Returns the keys defined in the session, sorted.
+func (s *session) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Sorted(maps.Keys(s.v))
+}
+
+// Writes the encrypted session payload into the response cookies, or
+// removes them if the session was reaped. Does nothing when the session
+// wasn't changed.
+func (s *session) Save(w http.ResponseWriter) error {
+	defer s.s.release(s)
+	opts, maxAge := s.s.cookieOptions()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reaped {
+		for i := 0; i < s.chunks; i++ {
+			http.SetCookie(w, s.s.cookie(opts, i, "", -1))
+		}
+		s.chunks = 0
+		return nil
+	}
+	if !s.dirty {
+		return nil
+	}
+	payload, err := s.s.encode(s)
+	if err != nil {
+		return err
+	}
+	n := (len(payload) + chunkSize - 1) / chunkSize
+	if n > maxChunks {
+		return ErrPayloadTooLarge
+	}
+	for i := 0; i < n; i++ {
+		http.SetCookie(w, s.s.cookie(opts, i, payload[i*chunkSize:min((i+1)*chunkSize, len(payload))], maxAge))
+	}
+	for i := n; i < s.chunks; i++ {
+		http.SetCookie(w, s.s.cookie(opts, i, "", -1))
+	}
+	if s.chunks == 0 {
+		// the client may still hold the payload of a session that
+		// wasn't loaded (e.g. expired), which must not be mixed up
+		http.SetCookie(w, s.s.cookie(opts, n, "", -1))
+	}
+	s.chunks = n
+	s.dirty = false
+	return nil
+}
+
+type entry struct {
+	sess *session
+	refs int // requests that loaded the session
+}
+
+type storage struct {
+	mu       sync.Mutex
+	name     string
+	aeads    []cipher.AEAD
+	opts     sessionpkg.CookieOptions
+	maxAge   int
+	sessions map[string]*entry
+	logger   *slog.Logger
+}
+
+// Returns a storage that keeps the whole session in the client cookies,
+// encrypted and authenticated with AES-GCM, so no state is kept in the
+// server.
+//
+// The cookies are named after name, split into name, name_1, name_2 and
+// so on when the payload exceeds a single cookie size. Each key must
+// have 16, 24 or 32 bytes (AES-128, AES-192 or AES-256). The first key
+// encrypts, while all of them decrypt, so keys can be rotated by adding
+// the new one at the beginning.
+//
+// The session payload only reaches the storage through Middleware(),
+// which must wrap the Manager middleware. The values are encoded with
+// encoding/gob, so types other than the basic ones and map[string]any
+// must be registered through gob.Register().
+func NewStorage(name string, keys ...[]byte) (*storage, error) {
+	if name == "" {
+		panic("empty cookie name")
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	s := &storage{
+		name:     name,
+		opts:     sessionpkg.DefaultCookieOptions(),
+		sessions: map[string]*entry{},
+	}
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		s.aeads = append(s.aeads, aead)
+	}
+	return s, nil
+}
+
+// Sets the attributes for the payload cookies, returning error if they
+// cannot be used with the storage cookie name.
+func (s *storage) SetCookieOptions(opts sessionpkg.CookieOptions) error {
+	if err := opts.Validate(s.name); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts = opts
+	return nil
+}
+
+// Sets the lifetime of the payload cookies, in seconds, which should be
+// the one of the session identifier cookie (the max age given to
+// NewManager(), or the idle timeout when it's shorter). Zero makes them
+// last until the browser is closed, which is the default.
+func (s *storage) SetMaxAge(maxAge int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxAge = maxAge
+}
+
+// Returns the attributes and the lifetime of the payload cookies.
+func (s *storage) cookieOptions() (sessionpkg.CookieOptions, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opts, s.maxAge
+}
+
+func (s *storage) chunkName(i int) string {
+	if i == 0 {
+		return s.name
+	}
+	return s.name + "_" + strconv.Itoa(i)
+}
+
+func (s *storage) cookie(opts sessionpkg.CookieOptions, i int, value string, maxAge int) *http.Cookie {
+	path := opts.Path
+	if path == "" {
+		path = "/"
+	}
+	c := &http.Cookie{
+		Name:        s.chunkName(i),
+		Value:       value,
+		Path:        path,
+		Domain:      opts.Domain,
+		Secure:      opts.Secure,
+		HttpOnly:    opts.HttpOnly,
+		SameSite:    opts.SameSite,
+		Partitioned: opts.Partitioned,
+		MaxAge:      maxAge,
+	}
+	if maxAge < 0 {
+		c.Expires = time.Now()
+	}
+	return c
+}
+
+func (s *storage) encode(sess *session) (string, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&extSession{
+		Id: sess.id,
+		V:  sess.v,
+		Ct: sess.ct.UnixNano(),
+		At: sess.at.UnixNano(),
+	})
+	if err != nil {
+		return "", err
+	}
+	aead := s.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+buf.Len()+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, buf.Bytes(), []byte(s.name))), nil
+}
+
+func (s *storage) decode(payload string) (*session, error) {
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+	for _, aead := range s.aeads {
+		if len(b) < aead.NonceSize() {
+			continue
+		}
+		plain, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(s.name))
+		if err != nil {
+			continue
+		}
+		var esess extSession
+		if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(&esess); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+		}
+		if esess.V == nil {
+			esess.V = map[string]any{}
+		}
+		return &session{
+			id: esess.Id,
+			v:  esess.V,
+			ct: time.Unix(0, esess.Ct),
+			at: time.Unix(0, esess.At),
+			s:  s,
+		}, nil
+	}
+	return nil, ErrInvalidPayload
+}
+
+// Reads the session payload from the request cookies.
+func (s *storage) load(r *http.Request) (*session, error) {
+	var payload string
+	chunks := 0
+	for ; chunks < maxChunks; chunks++ {
+		c, err := r.Cookie(s.chunkName(chunks))
+		if err != nil {
+			break
+		}
+		payload += c.Value
+	}
+	if chunks == 0 {
+		return nil, nil
+	}
+	sess, err := s.decode(payload)
+	if err != nil {
+		return nil, err
+	}
+	sess.chunks = chunks
+	return sess, nil
+}
+
+// Returns a http handler that loads the session payload from the request
+// cookies, making it available to the storage while next handler runs.
+// It must wrap the Manager middleware, which saves the session into the
+// response cookies:
+//
+//	storage.Middleware(manager.Middleware(handler))
+//
+// An invalid payload (e.g. encrypted with an unknown key) is ignored, and
+// logged if there's a logger (see SetLogger()).
+func (s *storage) Middleware(next http.Handler) http.Handler {
+	if next == nil {
+		panic("nil handler")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, err := s.load(r)
+		if err != nil {
+			s.mu.Lock()
+			logger := s.logger
+			s.mu.Unlock()
+			if logger != nil {
+				logger.Warn("cookie: ignored invalid session payload", "err", err)
+			}
+		}
+		if sess == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.mu.Lock()
+		e, ok := s.sessions[sess.id]
+		if !ok {
+			e = &entry{sess: sess}
+			s.sessions[sess.id] = e
+		}
+		e.refs++
+		s.mu.Unlock()
+
+		defer func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if e.refs--; e.refs == 0 && s.sessions[e.sess.id] == e {
+				delete(s.sessions, e.sess.id)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Sets the logger, which reports the invalid payloads ignored by
+// Middleware(). Setting nil stops logging, which is the default.
+func (s *storage) SetLogger(l *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = l
+}
+
+// Forgets the session after it's saved, unless some request loaded it.
+func (s *storage) release(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.sessions[sess.id]; ok && e.sess == sess && e.refs == 0 {
+		delete(s.sessions, sess.id)
+	}
+}
+
+// Returns a new session, which is sent to the client when saved.
+func (s *storage) CreateSession(sid string) (sessionpkg.Session, error) {
+	if sid == "" {
+		panic("empty sid")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := newSession(s, sid)
+	s.sessions[sid] = &entry{sess: sess}
+	return sess, nil
+}
+
+// Returns the session loaded from the request cookies, or nil if there's
+// none for the identifier.
+func (s *storage) GetSession(sid string) (sessionpkg.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.sessions[sid]; ok {
+		return e.sess, nil
+	}
+	return nil, nil
+}
+
+// Checks if the session was loaded from the request cookies (or created).
+func (s *storage) ContainsSession(sid string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.sessions[sid]
+	return ok, nil
+}
+
+// Destroys the session, which is removed from the client when saved.
+func (s *storage) ReapSession(sid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.sessions[sid]; ok {
+		e.sess.mu.Lock()
+		e.sess.reaped = true
+		e.sess.mu.Unlock()
+		delete(s.sessions, sid)
+	}
+	return nil
+}
+
+// Moves the session values to a new session identifier, keeping it's
+// creation time. Returns nil if there's no session for the old one, or
+// an error if the new one is already in use.
+func (s *storage) RegenerateSession(oldSid, newSid string) (sessionpkg.Session, error) {
+	if newSid == "" {
+		panic("empty sid")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.sessions[oldSid]
+	if !ok {
+		return nil, nil
+	}
+	if _, ok := s.sessions[newSid]; ok {
+		return nil, sessionpkg.ErrDuplicatedSessionId
+	}
+	old := e.sess
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	sess := &session{
+		id:     newSid,
+		v:      maps.Clone(old.v),
+		ct:     old.ct,
+		at:     old.at,
+		chunks: old.chunks,
+		dirty:  true,
+		s:      s,
+	}
+	delete(s.sessions, oldSid)
+	s.sessions[newSid] = &entry{sess: sess}
+	return sess, nil
+}
+
+// Updates the session access time, which is sent to the client when
+// saved.
+func (s *storage) TouchSession(sid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.sessions[sid]; ok {
+		e.sess.mu.Lock()
+		e.sess.at = time.Now()
+		e.sess.dirty = true
+		e.sess.mu.Unlock()
+	}
+	return nil
+}
+
+// Scans the sessions held by the storage (the ones loaded or created by
+// requests in progress) removing expired ones, returning them. The expiration of the client cookies is up to the
+// provider and the cookie attributes.
+func (s *storage) Deadline(absolute, idle sessionpkg.AgeChecker) ([]sessionpkg.ExpiredSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reaped []sessionpkg.ExpiredSession
+	for sid, e := range s.sessions {
+		e.sess.mu.Lock()
+		ct, at := e.sess.ct, e.sess.at
+		e.sess.mu.Unlock()
+		if lifetime := sessionpkg.ExpiredLifetime(absolute, idle, ct, at); lifetime != "" {
+			delete(s.sessions, sid)
+			reaped = append(reaped, sessionpkg.ExpiredSession{
+				SessionInfo: sessionpkg.SessionInfo{ID: sid, Created: ct, Accessed: at},
+				Lifetime:    lifetime,
+			})
+		}
+	}
+	return reaped, nil
+}
+
+// Returns ErrNotSupported. The sessions are kept by the clients, so they
+// cannot be indexed by user.
+func (s *storage) BindUser(sid, uid string, limit sessionpkg.UserLimit) ([]string, error) {
+	return nil, sessionpkg.ErrNotSupported
+}
+
+// Returns ErrNotSupported. The sessions are kept by the clients, so they
+// cannot be indexed by user.
+func (s *storage) UserSessions(uid string) ([]string, error) {
+	return nil, sessionpkg.ErrNotSupported
+}
+
+// Returns ErrNotSupported. The sessions are kept by the clients, so they
+// cannot be enumerated.
+func (s *storage) ListSessions() ([]sessionpkg.SessionInfo, error) {
+	return nil, sessionpkg.ErrNotSupported
+}
+
+func init() {
+	gob.Register(map[string]any{})
+	gob.Register([]any{})
+}
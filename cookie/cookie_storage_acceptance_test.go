@@ -0,0 +1,85 @@
+package cookie_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xandalm/go-session"
+	"github.com/xandalm/go-session/cookie"
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestSessionLifecycleThroughCookies(t *testing.T) {
+	storage, err := cookie.NewStorage("SESSION_DATA", bytes.Repeat([]byte{1}, 32))
+	assert.NoError(t, err)
+	manager := session.NewManager(session.NewProvider(storage, session.SecondsAgeCheckerAdapter), "SESSION_ID", 3600)
+
+	var got any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/set", func(w http.ResponseWriter, r *http.Request) {
+		session.FromContext(r.Context()).Set("username", "xandalm")
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		got = session.FromContext(r.Context()).Get("username")
+	})
+	mux.HandleFunc("/destroy", func(w http.ResponseWriter, r *http.Request) {
+		manager.DestroySession(w, r)
+	})
+	handler := storage.Middleware(manager.Middleware(mux))
+
+	var cookies []*http.Cookie
+	do := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://site.com"+path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		for _, c := range res.Result().Cookies() {
+			cookies = setCookie(cookies, c)
+		}
+		return res
+	}
+
+	t.Run("set session value", func(t *testing.T) {
+		do("/set")
+
+		if len(cookies) != 2 {
+			t.Fatalf("expected the id and data cookies, got %v", cookies)
+		}
+	})
+	t.Run("get session value", func(t *testing.T) {
+		do("/get")
+
+		assert.Equal(t, got, any("xandalm"))
+	})
+	t.Run("destroy session", func(t *testing.T) {
+		do("/destroy")
+
+		if len(cookies) != 0 {
+			t.Errorf("expected the cookies to be removed, got %v", cookies)
+		}
+	})
+	t.Run("get value from new session", func(t *testing.T) {
+		do("/get")
+
+		assert.Nil(t, got)
+	})
+}
+
+// Keeps the cookies like a browser, replacing them by name and dropping
+// the expired ones.
+func setCookie(cookies []*http.Cookie, c *http.Cookie) []*http.Cookie {
+	kept := cookies[:0]
+	for _, old := range cookies {
+		if old.Name != c.Name {
+			kept = append(kept, old)
+		}
+	}
+	if c.MaxAge >= 0 {
+		kept = append(kept, c)
+	}
+	return kept
+}
@@ -0,0 +1,340 @@
+package cookie
+
+import (
+	"bytes"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sessionpkg "github.com/xandalm/go-session"
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+var (
+	dummyKey    = bytes.Repeat([]byte{1}, 32)
+	dummyOldKey = bytes.Repeat([]byte{2}, 16)
+)
+
+func newTestStorage(t testing.TB, keys ...[]byte) *storage {
+	t.Helper()
+	s, err := NewStorage("SESSION", keys...)
+	assert.NoError(t, err)
+	return s
+}
+
+// Returns the response cookies that aren't expired.
+func storedCookies(res *httptest.ResponseRecorder) []*http.Cookie {
+	var cookies []*http.Cookie
+	for _, c := range res.Result().Cookies() {
+		if c.MaxAge >= 0 {
+			cookies = append(cookies, c)
+		}
+	}
+	return cookies
+}
+
+func requestWithCookies(res *httptest.ResponseRecorder) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "http://site.com", nil)
+	for _, c := range storedCookies(res) {
+		req.AddCookie(c)
+	}
+	return req
+}
+
+func TestNewStorage(t *testing.T) {
+	t.Run("returns error without keys", func(t *testing.T) {
+		_, err := NewStorage("SESSION")
+
+		assert.Equal(t, err, ErrNoKeys)
+	})
+	t.Run("returns error for invalid key size", func(t *testing.T) {
+		_, err := NewStorage("SESSION", []byte("short"))
+
+		assert.Error(t, err)
+	})
+}
+
+func TestSession_SetAndDelete(t *testing.T) {
+	sess := newSession(newTestStorage(t, dummyKey), "abcde")
+	sess.dirty = false
+
+	err := sess.Set("foo", "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, sess.Get("foo"), any("bar"))
+	assert.Equal(t, sess.dirty, true)
+
+	sess.dirty = false
+	err = sess.Delete("foo")
+	assert.NoError(t, err)
+	assert.Nil(t, sess.Get("foo"))
+	assert.Equal(t, sess.dirty, true)
+}
+
+func TestSession_Save(t *testing.T) {
+	t.Run("writes the encrypted payload", func(t *testing.T) {
+		s := newTestStorage(t, dummyKey)
+		sess := newSession(s, "abcde")
+		sess.Set("foo", "plain-secret")
+
+		res := httptest.NewRecorder()
+		err := sess.Save(res)
+		assert.NoError(t, err)
+
+		cookies := storedCookies(res)
+		if len(cookies) != 1 {
+			t.Fatalf("expected one cookie, got %d", len(cookies))
+		}
+		if strings.Contains(cookies[0].Value, "plain-secret") {
+			t.Error("didn't encrypt the payload")
+		}
+
+		got, err := s.load(requestWithCookies(res))
+		assert.NoError(t, err)
+		assert.NotNil(t, got)
+		assert.Equal(t, got.id, "abcde")
+		assert.Equal(t, got.Get("foo"), any("plain-secret"))
+		if !got.ct.Equal(sess.ct) {
+			t.Errorf("got creation time %v, but want %v", got.ct, sess.ct)
+		}
+	})
+	t.Run("writes the payload with the storage max age", func(t *testing.T) {
+		s := newTestStorage(t, dummyKey)
+		s.SetMaxAge(3600)
+		sess := newSession(s, "abcde")
+
+		res := httptest.NewRecorder()
+		err := sess.Save(res)
+		assert.NoError(t, err)
+
+		cookies := storedCookies(res)
+		if len(cookies) != 1 {
+			t.Fatalf("expected one cookie, got %d", len(cookies))
+		}
+		assert.Equal(t, cookies[0].MaxAge, 3600)
+	})
+	t.Run("can change the options while saving", func(t *testing.T) {
+		s := newTestStorage(t, dummyKey)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range 100 {
+				s.SetCookieOptions(sessionpkg.CookieOptions{Path: "/foo"})
+			}
+		}()
+		for range 100 {
+			assert.NoError(t, newSession(s, "abcde").Save(httptest.NewRecorder()))
+		}
+		<-done
+	})
+	t.Run("splits large payload into chunks", func(t *testing.T) {
+		s := newTestStorage(t, dummyKey)
+		sess := newSession(s, "abcde")
+		large := make([]byte, 3*chunkSize)
+		rand.Read(large)
+		sess.Set("large", large)
+
+		res := httptest.NewRecorder()
+		err := sess.Save(res)
+		assert.NoError(t, err)
+
+		cookies := storedCookies(res)
+		if len(cookies) < 2 {
+			t.Fatalf("expected payload split into cookies, got %d", len(cookies))
+		}
+		for _, c := range cookies {
+			if len(c.Value) > chunkSize {
+				t.Errorf("cookie %s exceeds chunk size, got %d", c.Name, len(c.Value))
+			}
+		}
+
+		got, err := s.load(requestWithCookies(res))
+		assert.NoError(t, err)
+		assert.Equal(t, got.Get("large"), any(large))
+		assert.Equal(t, got.chunks, len(cookies))
+
+		t.Run("and expires the chunks left over", func(t *testing.T) {
+			got.Delete("large")
+
+			res := httptest.NewRecorder()
+			err := got.Save(res)
+			assert.NoError(t, err)
+
+			expired := 0
+			for _, c := range res.Result().Cookies() {
+				if c.MaxAge < 0 {
+					expired++
+				}
+			}
+			assert.Equal(t, expired, len(cookies)-1)
+		})
+	})
+	t.Run("expires the chunk after the payload of new session", func(t *testing.T) {
+		sess := newSession(newTestStorage(t, dummyKey), "abcde")
+
+		res := httptest.NewRecorder()
+		err := sess.Save(res)
+		assert.NoError(t, err)
+
+		cookies := res.Result().Cookies()
+		if len(cookies) != 2 || cookies[1].Name != "SESSION_1" || cookies[1].MaxAge >= 0 {
+			t.Errorf("didn't expire the next chunk, got %v", cookies)
+		}
+	})
+	t.Run("returns error for payload over the limit", func(t *testing.T) {
+		sess := newSession(newTestStorage(t, dummyKey), "abcde")
+		large := make([]byte, maxChunks*chunkSize)
+		rand.Read(large)
+		sess.Set("large", large)
+
+		err := sess.Save(httptest.NewRecorder())
+
+		assert.Equal(t, err, ErrPayloadTooLarge)
+	})
+	t.Run("doesn't write unchanged session", func(t *testing.T) {
+		sess := newSession(newTestStorage(t, dummyKey), "abcde")
+		sess.dirty = false
+
+		res := httptest.NewRecorder()
+		err := sess.Save(res)
+
+		assert.NoError(t, err)
+		assert.Equal(t, len(res.Result().Cookies()), 0)
+	})
+	t.Run("expires the cookies of reaped session", func(t *testing.T) {
+		s := newTestStorage(t, dummyKey)
+		sess, _ := s.CreateSession("abcde")
+		sess.(*session).chunks = 2
+
+		err := s.ReapSession("abcde")
+		assert.NoError(t, err)
+
+		res := httptest.NewRecorder()
+		err = sess.(*session).Save(res)
+		assert.NoError(t, err)
+
+		cookies := res.Result().Cookies()
+		if len(cookies) != 2 || cookies[0].MaxAge >= 0 || cookies[1].MaxAge >= 0 {
+			t.Errorf("didn't expire cookies, got %v", cookies)
+		}
+	})
+}
+
+func TestStorage_Load(t *testing.T) {
+	sess := newSession(newTestStorage(t, dummyOldKey), "abcde")
+	res := httptest.NewRecorder()
+	sess.Save(res)
+
+	t.Run("decrypts payload with old key", func(t *testing.T) {
+		s := newTestStorage(t, dummyKey, dummyOldKey)
+
+		got, err := s.load(requestWithCookies(res))
+
+		assert.NoError(t, err)
+		assert.NotNil(t, got)
+		assert.Equal(t, got.id, "abcde")
+	})
+	t.Run("returns error for unknown key", func(t *testing.T) {
+		s := newTestStorage(t, dummyKey)
+
+		_, err := s.load(requestWithCookies(res))
+
+		assert.Equal(t, err, ErrInvalidPayload)
+	})
+	t.Run("returns error for tampered payload", func(t *testing.T) {
+		s := newTestStorage(t, dummyOldKey)
+		req, _ := http.NewRequest(http.MethodGet, "http://site.com", nil)
+		c := res.Result().Cookies()[0]
+		c.Value = c.Value[:len(c.Value)-2] + "AA"
+		req.AddCookie(c)
+
+		_, err := s.load(req)
+
+		assert.Equal(t, err, ErrInvalidPayload)
+	})
+}
+
+func TestStorage_Middleware(t *testing.T) {
+	s := newTestStorage(t, dummyKey)
+	sess := newSession(s, "abcde")
+	res := httptest.NewRecorder()
+	sess.Save(res)
+
+	var got any
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = s.GetSession("abcde")
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), requestWithCookies(res))
+
+	assert.NotNil(t, got)
+	if ok, _ := s.ContainsSession("abcde"); ok {
+		t.Error("didn't forget the session after the request")
+	}
+}
+
+func TestStorage_RegenerateSession(t *testing.T) {
+	s := newTestStorage(t, dummyKey)
+	sess, _ := s.CreateSession("abcde")
+	sess.Set("foo", "bar")
+
+	got, err := s.RegenerateSession("abcde", "fghij")
+
+	assert.NoError(t, err)
+	assert.Equal(t, got.SessionID(), "fghij")
+	assert.Equal(t, got.Get("foo"), any("bar"))
+	if ok, _ := s.ContainsSession("abcde"); ok {
+		t.Error("didn't remove old session")
+	}
+}
+
+func TestStorage_Deadline(t *testing.T) {
+	s := newTestStorage(t, dummyKey)
+	sess, _ := s.CreateSession("abcde")
+	sess.(*session).ct = time.Now().Add(-time.Second)
+	s.CreateSession("fghij")
+
+	s.Deadline(stubMilliAgeChecker(500), nil)
+
+	if ok, _ := s.ContainsSession("abcde"); ok {
+		t.Error("didn't remove expired session")
+	}
+	if ok, _ := s.ContainsSession("fghij"); !ok {
+		t.Error("removed session that isn't expired")
+	}
+}
+
+func TestStorage_TouchSession(t *testing.T) {
+	s := newTestStorage(t, dummyKey)
+	sess, _ := s.CreateSession("abcde")
+	sess.(*session).at = time.Now().Add(-time.Second)
+	sess.(*session).dirty = false
+
+	err := s.TouchSession("abcde")
+
+	assert.NoError(t, err)
+	assert.Equal(t, sess.(*session).dirty, true)
+	if time.Since(sess.(*session).at) > 100*time.Millisecond {
+		t.Error("didn't update access time")
+	}
+}
+
+type stubMilliAgeChecker int64
+
+func (c stubMilliAgeChecker) ShouldReap(t time.Time) bool {
+	return time.Now().UnixMilli()-t.UnixMilli() >= int64(c)
+}
+
+func TestStorage_UserIndex(t *testing.T) {
+	s := newTestStorage(t, dummyKey)
+	s.CreateSession("abcde")
+
+	_, err := s.BindUser("abcde", "alex", sessionpkg.UserLimit{})
+	assert.Equal(t, err, sessionpkg.ErrNotSupported)
+
+	_, err = s.UserSessions("alex")
+	assert.Equal(t, err, sessionpkg.ErrNotSupported)
+}
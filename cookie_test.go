@@ -0,0 +1,101 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestCookieOptions_Validate(t *testing.T) {
+	cases := []struct {
+		desc  string
+		name  string
+		opts  CookieOptions
+		valid bool
+	}{
+		{"default options", "SessionID", DefaultCookieOptions(), true},
+		{"relative path", "SessionID", CookieOptions{Path: "admin"}, false},
+		{"secure prefix without secure", "__Secure-SessionID", CookieOptions{}, false},
+		{"secure prefix with secure", "__Secure-SessionID", CookieOptions{Secure: true, Domain: "site.com", Path: "/admin"}, true},
+		{"host prefix without secure", "__Host-SessionID", CookieOptions{Path: "/"}, false},
+		{"host prefix with domain", "__Host-SessionID", CookieOptions{Secure: true, Domain: "site.com"}, false},
+		{"host prefix with sub-path", "__Host-SessionID", CookieOptions{Secure: true, Path: "/admin"}, false},
+		{"host prefix", "__Host-SessionID", CookieOptions{Secure: true, Path: "/"}, true},
+		{"same site none without secure", "SessionID", CookieOptions{SameSite: http.SameSiteNoneMode}, false},
+		{"partitioned without secure", "SessionID", CookieOptions{Partitioned: true}, false},
+		{"partitioned", "SessionID", CookieOptions{Secure: true, Partitioned: true, SameSite: http.SameSiteNoneMode}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := c.opts.Validate(c.name)
+			if c.valid {
+				assert.NoError(t, err)
+				return
+			}
+			if !errors.Is(err, ErrInvalidCookieOptions) {
+				t.Errorf("expected ErrInvalidCookieOptions, got %v", err)
+			}
+		})
+	}
+}
+
+func TestManager_SetCookieOptions(t *testing.T) {
+	provider := &stubProvider{}
+
+	t.Run("uses options to start and destroy the session", func(t *testing.T) {
+		manager := NewManager(provider, "__Host-SessionID", 3600)
+		err := manager.SetCookieOptions(CookieOptions{
+			Secure:      true,
+			HttpOnly:    true,
+			SameSite:    http.SameSiteStrictMode,
+			Partitioned: true,
+		})
+		assert.NoError(t, err)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+
+		manager.StartSession(res, req)
+
+		cookie := res.Result().Cookies()[0]
+		assert.Equal(t, cookie.Path, "/")
+		assert.Equal(t, cookie.Secure, true)
+		assert.Equal(t, cookie.HttpOnly, true)
+		assert.Equal(t, cookie.SameSite, http.SameSiteStrictMode)
+		assert.Equal(t, cookie.Partitioned, true)
+
+		req, _ = http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(cookie)
+		res = httptest.NewRecorder()
+
+		manager.DestroySession(res, req)
+
+		cookie = res.Result().Cookies()[0]
+		assert.Equal(t, cookie.MaxAge, -1)
+		assert.Equal(t, cookie.Secure, true)
+		assert.Equal(t, cookie.SameSite, http.SameSiteStrictMode)
+	})
+	t.Run("defaults to secure cookie for prefixed name", func(t *testing.T) {
+		manager := NewManager(provider, "__Secure-SessionID", 3600)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+
+		manager.StartSession(res, req)
+
+		cookie := res.Result().Cookies()[0]
+		assert.Equal(t, cookie.Secure, true)
+	})
+	t.Run("returns error for invalid options", func(t *testing.T) {
+		manager := NewManager(provider, "__Host-SessionID", 3600)
+
+		err := manager.SetCookieOptions(CookieOptions{Secure: true, Domain: "site.com"})
+
+		assert.Error(t, err)
+		assert.Equal(t, manager.cookie.opts, defaultCookieOptionsFor("__Host-SessionID"))
+	})
+}
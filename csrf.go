@@ -0,0 +1,126 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	// Session key holding the CSRF secret, which shouldn't be used for
+	// other values.
+	CSRFKey = "_csrf"
+	// Request header carrying the CSRF token.
+	CSRFHeader = "X-CSRF-Token"
+	// Form field carrying the CSRF token.
+	CSRFField = "csrf_token"
+
+	csrfSecretSize = 32
+)
+
+var (
+	ErrNoSession          error = errors.New("session: no session in the request context")
+	ErrUnableToCreateCSRF error = errors.New("session: unable to create csrf secret")
+	csrfEncoding                = base64.RawURLEncoding
+)
+
+// Returns the CSRF secret stored into the session, or nil if there's
+// none.
+func csrfSecret(sess Session) []byte {
+	v, _ := sess.Get(CSRFKey).(string)
+	secret, err := csrfEncoding.DecodeString(v)
+	if err != nil || len(secret) != csrfSecretSize {
+		return nil
+	}
+	return secret
+}
+
+// Returns a CSRF token for the session, creating its secret when the
+// session has none. The token is masked with random bytes, so it changes
+// on every call (which resists BREACH), while any of them is valid until
+// the session ends.
+//
+// Returns an error, wrapping ErrUnableToCreateCSRF, when the secret
+// cannot be created or stored into the session.
+func CSRFToken(sess Session) (string, error) {
+	secret := csrfSecret(sess)
+	if secret == nil {
+		secret = make([]byte, csrfSecretSize)
+		if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrUnableToCreateCSRF, err)
+		}
+		if err := sess.Set(CSRFKey, csrfEncoding.EncodeToString(secret)); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrUnableToCreateCSRF, err)
+		}
+	}
+	token := make([]byte, 2*csrfSecretSize)
+	if _, err := io.ReadFull(rand.Reader, token[:csrfSecretSize]); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUnableToCreateCSRF, err)
+	}
+	subtle.XORBytes(token[csrfSecretSize:], token[:csrfSecretSize], secret)
+	return csrfEncoding.EncodeToString(token), nil
+}
+
+// Checks if the token was created for the session through CSRFToken().
+func VerifyCSRFToken(sess Session, token string) bool {
+	secret := csrfSecret(sess)
+	if secret == nil {
+		return false
+	}
+	b, err := csrfEncoding.DecodeString(token)
+	if err != nil || len(b) != 2*csrfSecretSize {
+		return false
+	}
+	subtle.XORBytes(b[csrfSecretSize:], b[csrfSecretSize:], b[:csrfSecretSize])
+	return subtle.ConstantTimeCompare(b[csrfSecretSize:], secret) == 1
+}
+
+// Returns a CSRF token for the request session (see CSRFToken()), to be
+// sent back through the CSRFField form field or the CSRFHeader header.
+// It's meant for templates, within handlers wrapped by Middleware().
+//
+// Returns ErrNoSession if the request context has no session.
+func (m *Manager) CSRFToken(r *http.Request) (string, error) {
+	sess := FromContext(r.Context())
+	if sess == nil {
+		return "", ErrNoSession
+	}
+	return CSRFToken(sess)
+}
+
+// Returns a http handler that checks the CSRF token of requests with
+// unsafe methods (other than GET, HEAD, OPTIONS and TRACE), responding
+// with forbidden status when it's invalid. The token is read from the
+// CSRFHeader header or, if there's none, from the CSRFField form field.
+//
+// It uses the request context session, so it must be wrapped by the
+// Manager middleware:
+//
+//	manager.Middleware(manager.CSRF(handler))
+func (m *Manager) CSRF(next http.Handler) http.Handler {
+	if next == nil {
+		panic("nil handler")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !safeMethods[r.Method] {
+			sess := FromContext(r.Context())
+			if sess == nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			token := r.Header.Get(CSRFHeader)
+			if token == "" {
+				token = r.PostFormValue(CSRFField)
+			}
+			if !VerifyCSRFToken(sess, token) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
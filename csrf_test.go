@@ -0,0 +1,116 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestCSRFToken(t *testing.T) {
+	sess := newStubSession("abcde")
+
+	token, err := CSRFToken(sess)
+	assert.NoError(t, err)
+	assert.NotNil(t, sess.Get(CSRFKey))
+
+	t.Run("masks token on every call", func(t *testing.T) {
+		other, err := CSRFToken(sess)
+		assert.NoError(t, err)
+
+		if other == token {
+			t.Error("didn't mask the token")
+		}
+		assert.Equal(t, VerifyCSRFToken(sess, token), true)
+		assert.Equal(t, VerifyCSRFToken(sess, other), true)
+	})
+	t.Run("rejects invalid tokens", func(t *testing.T) {
+		assert.Equal(t, VerifyCSRFToken(sess, ""), false)
+		assert.Equal(t, VerifyCSRFToken(sess, "abcde"), false)
+		// the first character has no padding bits, so changing it always
+		// changes the token bytes
+		tampered := []byte(token)
+		if tampered[0] == 'A' {
+			tampered[0] = 'B'
+		} else {
+			tampered[0] = 'A'
+		}
+		assert.Equal(t, VerifyCSRFToken(sess, string(tampered)), false)
+	})
+	t.Run("rejects token of another session", func(t *testing.T) {
+		other := newStubSession("fghij")
+		CSRFToken(other)
+
+		assert.Equal(t, VerifyCSRFToken(other, token), false)
+	})
+	t.Run("rejects token for session without secret", func(t *testing.T) {
+		assert.Equal(t, VerifyCSRFToken(newStubSession("fghij"), token), false)
+	})
+}
+
+func TestManager_CSRF(t *testing.T) {
+	manager := NewManager(&stubProvider{}, "SessionID", 3600)
+
+	var token string
+	handler := manager.Middleware(manager.CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		token, err = manager.CSRFToken(r)
+		assert.NoError(t, err)
+	})))
+
+	req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	assert.Equal(t, res.Code, http.StatusOK)
+	cookie := res.Result().Cookies()[0]
+
+	t.Run("accepts token from header", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, dummySite, nil)
+		req.AddCookie(cookie)
+		req.Header.Set(CSRFHeader, token)
+		res := httptest.NewRecorder()
+
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, res.Code, http.StatusOK)
+	})
+	t.Run("accepts token from form", func(t *testing.T) {
+		form := url.Values{CSRFField: {token}}
+		req, _ := http.NewRequest(http.MethodPost, dummySite, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		res := httptest.NewRecorder()
+
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, res.Code, http.StatusOK)
+	})
+	t.Run("forbids request without token", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodDelete, dummySite, nil)
+		req.AddCookie(cookie)
+		res := httptest.NewRecorder()
+
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, res.Code, http.StatusForbidden)
+	})
+	t.Run("forbids token of another session", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, dummySite, nil)
+		req.Header.Set(CSRFHeader, token)
+		res := httptest.NewRecorder()
+
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, res.Code, http.StatusForbidden)
+	})
+	t.Run("returns error without session in context", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+
+		_, err := manager.CSRFToken(req)
+
+		assert.Equal(t, err, ErrNoSession)
+	})
+}
@@ -0,0 +1,165 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies what happened to a session.
+type EventType int
+
+const (
+	// The session identifier sent by the client was rejected, and a
+	// new session was started in its place.
+	EventRejected EventType = iota + 1
+	// The session was removed to keep the user under the session limit.
+	EventEvicted
+	// The session was created.
+	EventCreated
+	// The session was read (e.g. when it's started with an identifier sent
+	// by the client).
+	EventRead
+	// The session was destroyed.
+	EventDestroyed
+	// The session expired and was removed, either when read or by the GC
+	// routine.
+	EventExpired
+	// The session was moved under a new identifier.
+	EventRegenerated
+)
+
+// Event describes something that happened to a session.
+type Event struct {
+	Type     EventType
+	SID      string    // session identifier
+	Time     time.Time // when it happened
+	Created  time.Time // when the session was created, if known
+	Accessed time.Time // when the session was last accessed, if known
+	OldSID   string    // previous session identifier, when regenerated
+	UID      string    // user identifier, if the session is bound to one
+	Reason   string
+}
+
+// Returns the event for the session, with its timestamps.
+func sessionEvent(typ EventType, sess Session) Event {
+	return Event{
+		Type:     typ,
+		SID:      sess.SessionID(),
+		Created:  sess.CreationTime(),
+		Accessed: sess.AccessTime(),
+	}
+}
+
+// EventSource is implemented by providers that emit the session events
+// (e.g. the one returned by NewProvider()). The Manager subscribes to
+// them when created, so it calls its hooks with them.
+type EventSource interface {
+	// Registers a hook to be called with all the events.
+	Subscribe(fn Hook)
+}
+
+// Revoker is implemented by event sources that can tell why a session was
+// destroyed (e.g. the provider returned by NewProvider()). The Manager
+// revokes the sessions through it, so the destroyed events carry the
+// reason.
+type Revoker interface {
+	// Destroys the session like SessionDestroy(), emitting the destroyed
+	// event with the user identifier and the reason, when not empty.
+	SessionRevoke(sid, uid, reason string) error
+}
+
+// Hook is called with the events it was registered for.
+type Hook func(Event)
+
+type hook struct {
+	typ EventType
+	all bool // called for any type
+	fn  Hook
+}
+
+type events struct {
+	mu    sync.RWMutex
+	hooks []hook
+}
+
+func (e *events) on(typ EventType, fn Hook) {
+	if fn == nil {
+		panic("nil hook")
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.hooks = append(e.hooks, hook{typ: typ, fn: fn})
+}
+
+func (e *events) onAll(fn Hook) {
+	if fn == nil {
+		panic("nil hook")
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.hooks = append(e.hooks, hook{all: true, fn: fn})
+}
+
+func (e *events) emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	// The hooks are called without the lock, so they can register others.
+	e.mu.RLock()
+	hooks := e.hooks
+	e.mu.RUnlock()
+	for _, h := range hooks {
+		if h.all || h.typ == ev.Type {
+			h.fn(ev)
+		}
+	}
+}
+
+// Registers a hook to be called when the session identifier sent by
+// the client is rejected (e.g. unknown by the provider in strict mode).
+func (m *Manager) OnReject(fn Hook) {
+	m.events.on(EventRejected, fn)
+}
+
+// Registers a hook to be called when a session is evicted to keep the
+// user under the session limit (see SetUserLimit()).
+func (m *Manager) OnEvict(fn Hook) {
+	m.events.on(EventEvicted, fn)
+}
+
+// Registers a hook to be called when a session is created.
+func (m *Manager) OnCreate(fn Hook) {
+	m.events.on(EventCreated, fn)
+}
+
+// Registers a hook to be called when a session is read.
+func (m *Manager) OnRead(fn Hook) {
+	m.events.on(EventRead, fn)
+}
+
+// Registers a hook to be called when a session is destroyed (e.g. through
+// DestroySession() or RevokeUserSessions()).
+func (m *Manager) OnDestroy(fn Hook) {
+	m.events.on(EventDestroyed, fn)
+}
+
+// Registers a hook to be called when an expired session is removed, either
+// when read or by the GC routine, which only happens if the provider is an
+// EventSource. The reason tells which lifetime expired (LifetimeMaxAge or
+// LifetimeIdleTimeout).
+func (m *Manager) OnExpire(fn Hook) {
+	m.events.on(EventExpired, fn)
+}
+
+// Registers a hook to be called when a session is moved under a new
+// identifier (see RegenerateID()).
+func (m *Manager) OnRegenerate(fn Hook) {
+	m.events.on(EventRegenerated, fn)
+}
+
+// Emits the session lifecycle event, unless the provider emits it.
+func (m *Manager) emitLifecycle(ev Event) {
+	if !m.subscribed {
+		m.events.emit(ev)
+	}
+}
@@ -0,0 +1,147 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestEvents(t *testing.T) {
+	t.Run("calls hooks registered for the event type", func(t *testing.T) {
+		var e events
+		var got []Event
+		e.on(EventRejected, func(ev Event) {
+			got = append(got, ev)
+		})
+		e.on(EventType(0), func(ev Event) {
+			t.Error("didn't expect hook to be called")
+		})
+
+		e.emit(Event{Type: EventRejected, SID: "abcde"})
+
+		if len(got) != 1 {
+			t.Fatalf("expected one event, got %d", len(got))
+		}
+		assert.Equal(t, got[0].SID, "abcde")
+		if got[0].Time.IsZero() {
+			t.Error("didn't set event time")
+		}
+	})
+	t.Run("panic on nil hook", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if r != "nil hook" {
+				t.Errorf("didn't get expected panic, got: %v", r)
+			}
+		}()
+		var e events
+		e.on(EventRejected, nil)
+	})
+}
+
+func TestManager_LifecycleEvents(t *testing.T) {
+	record := func(manager *Manager) *[]Event {
+		var events []Event
+		fn := func(e Event) {
+			events = append(events, e)
+		}
+		manager.OnCreate(fn)
+		manager.OnRead(fn)
+		manager.OnDestroy(fn)
+		manager.OnRegenerate(fn)
+		return &events
+	}
+	lifecycle := func(t *testing.T, manager *Manager) {
+		t.Helper()
+		events := record(manager)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+		sess := manager.StartSession(res, req)
+		req.AddCookie(res.Result().Cookies()[0])
+
+		manager.StartSession(httptest.NewRecorder(), req)
+		res = httptest.NewRecorder()
+		regenerated, err := manager.RegenerateID(res, req)
+		assert.NoError(t, err)
+		req, _ = http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(res.Result().Cookies()[0])
+		manager.DestroySession(httptest.NewRecorder(), req)
+
+		want := []Event{
+			{Type: EventCreated, SID: sess.SessionID()},
+			{Type: EventRead, SID: sess.SessionID()},
+			{Type: EventRegenerated, SID: regenerated.SessionID(), OldSID: sess.SessionID()},
+			{Type: EventDestroyed, SID: regenerated.SessionID()},
+		}
+		if len(*events) != len(want) {
+			t.Fatalf("expected %d events, got %+v", len(want), *events)
+		}
+		for i, e := range *events {
+			assert.Equal(t, e.Type, want[i].Type)
+			assert.Equal(t, e.SID, want[i].SID)
+			assert.Equal(t, e.OldSID, want[i].OldSID)
+		}
+	}
+
+	t.Run("emits the events", func(t *testing.T) {
+		lifecycle(t, NewManager(&stubProvider{}, "SessionID", 3600))
+	})
+	t.Run("emits the provider events once", func(t *testing.T) {
+		provider := NewProvider(newStubSessionStorage(), SecondsAgeCheckerAdapter)
+		manager := NewManager(provider, "SessionID", 3600)
+
+		lifecycle(t, manager)
+	})
+	t.Run("emits created event for lazy sessions once persisted", func(t *testing.T) {
+		manager := NewManager(&stubProvider{}, "SessionID", 3600)
+		manager.SetLazy(true)
+		events := record(manager)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		sess := manager.StartSession(httptest.NewRecorder(), req)
+
+		if len(*events) != 0 {
+			t.Fatalf("didn't expect events, got %+v", *events)
+		}
+		assert.NoError(t, sess.Set("foo", "bar"))
+		if len(*events) != 1 || (*events)[0].Type != EventCreated {
+			t.Errorf("expected created event, got %+v", *events)
+		}
+	})
+	t.Run("hooks can use the manager", func(t *testing.T) {
+		for _, provider := range []Provider{
+			&stubProvider{},
+			NewProvider(newStubSessionStorage(), SecondsAgeCheckerAdapter),
+		} {
+			manager := NewManager(provider, "SessionID", 3600)
+			manager.OnCreate(func(e Event) {
+				manager.OnRead(func(e Event) {})
+				manager.SetIdleTimeout(0)
+			})
+			manager.OnDestroy(func(e Event) {
+				manager.RevokeUserSessions("foo")
+			})
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+				res := httptest.NewRecorder()
+				manager.StartSession(res, req)
+				req.AddCookie(res.Result().Cookies()[0])
+				manager.RegenerateID(httptest.NewRecorder(), req)
+				manager.DestroySession(httptest.NewRecorder(), req)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatalf("hooks deadlocked with %T", provider)
+			}
+		}
+	})
+}
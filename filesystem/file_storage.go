@@ -1,13 +1,18 @@
 package filesystem
 
 import (
+	"cmp"
 	"container/list"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -18,20 +23,37 @@ import (
 type extSession struct {
 	V      map[string]any
 	Ct, At int64
+	U      string
 }
 
 type session struct {
+	mu sync.RWMutex
 	id string
 	v  map[string]any
 	ct time.Time
 	at time.Time
+	u  string // bound user id, kept by the storage index
 }
 
 func (s *session) SessionID() string {
 	return s.id
 }
 
+func (s *session) CreationTime() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ct
+}
+
+func (s *session) AccessTime() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.at
+}
+
 func (s *session) Get(key string) any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.v[key]
 }
 
@@ -40,6 +62,8 @@ func (s *session) Set(key string, value any) error {
 	for rValue.Kind() == reflect.Pointer {
 		rValue = reflect.Indirect(rValue)
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.v[key] = s.mapped(rValue)
 	if err := _storage.update(s); err != nil {
 		return err
@@ -47,22 +71,30 @@ func (s *session) Set(key string, value any) error {
 	return nil
 }
 
+// Converts the value into basic types, maps and slices, so it can be
+// encoded without registering it's type (see encoding/gob). Structs
+// become maps of their exported fields.
 func (s *session) mapped(v reflect.Value) any {
 	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
 	case reflect.Func:
 		panic("cannot stores func into session")
 	case reflect.Chan:
 		panic("cannot stores chan into session")
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return s.mapped(v.Elem())
 	case reflect.Struct:
 		vFields := reflect.VisibleFields(v.Type())
 		m := map[string]any{}
 		for _, f := range vFields {
-			fValue := v.FieldByName(f.Name)
-			if fValue.Kind() == reflect.Struct || fValue.Kind() == reflect.Map {
-				m[f.Name] = s.mapped(fValue)
-			} else {
-				m[f.Name] = fValue.Interface()
+			if !f.IsExported() {
+				continue
 			}
+			m[f.Name] = s.mapped(v.FieldByIndex(f.Index))
 		}
 		return m
 	case reflect.Map:
@@ -71,12 +103,31 @@ func (s *session) mapped(v reflect.Value) any {
 			m[k.String()] = s.mapped(v.MapIndex(k))
 		}
 		return m
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Map, reflect.Interface, reflect.Pointer:
+			l := make([]any, v.Len())
+			for i := range l {
+				l[i] = s.mapped(v.Index(i))
+			}
+			return l
+		}
+		return v.Interface()
 	default:
 		return v.Interface()
 	}
 }
 
+// Returns the keys defined in the session, sorted.
+func (s *session) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Sorted(maps.Keys(s.v))
+}
+
 func (s *session) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	delete(s.v, key)
 	if err := _storage.update(s); err != nil {
 		return err
@@ -87,6 +138,17 @@ func (s *session) Delete(key string) error {
 type basicSessionInfo struct {
 	id string
 	ct int64
+	at int64
+	u  string
+}
+
+func (bsi *basicSessionInfo) info() sessionpkg.SessionInfo {
+	return sessionpkg.SessionInfo{
+		ID:       bsi.id,
+		Created:  time.Unix(0, bsi.ct),
+		Accessed: time.Unix(0, bsi.at),
+		UID:      bsi.u,
+	}
 }
 
 type storageIO interface {
@@ -94,7 +156,8 @@ type storageIO interface {
 	Read(sid string) (*session, error)
 	Write(sess *session) error
 	Delete(sid string) error
-	List() []string
+	Rename(oldSid, newSid string) error
+	List() ([]string, error)
 }
 
 type defaultStorageIO struct {
@@ -122,7 +185,11 @@ func (sio *defaultStorageIO) create(w io.Writer, sess *session) error {
 }
 
 func (sio *defaultStorageIO) Create(sid string) (*session, error) {
-	file, err := os.OpenFile(sio.filePath(sid), os.O_RDWR|os.O_CREATE, 0666)
+	path, err := sio.filePath(sid)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
 	if err != nil {
 		return nil, err
 	}
@@ -149,11 +216,16 @@ func (sio *defaultStorageIO) read(r io.Reader) (*session, error) {
 		ct: time.Unix(0, esess.Ct),
 		at: time.Unix(0, esess.At),
 		v:  esess.V,
+		u:  esess.U,
 	}, nil
 }
 
 func (sio *defaultStorageIO) Read(sid string) (*session, error) {
-	file, err := os.Open(sio.filePath(sid))
+	path, err := sio.filePath(sid)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -175,12 +247,17 @@ func (sio *defaultStorageIO) write(w io.Writer, sess *session) error {
 		Ct: sess.ct.UnixNano(),
 		At: sess.at.UnixNano(),
 		V:  sess.v,
+		U:  sess.u,
 	})
 	return err
 }
 
 func (sio *defaultStorageIO) Write(sess *session) error {
-	file, err := os.OpenFile(sio.filePath(sess.id), os.O_WRONLY, 0666)
+	path, err := sio.filePath(sess.id)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY, 0666)
 	if err != nil {
 		return err
 	}
@@ -193,77 +270,130 @@ func (sio *defaultStorageIO) Write(sess *session) error {
 }
 
 func (sio *defaultStorageIO) Delete(sid string) error {
-	return os.Remove(sio.filePath(sid))
+	path, err := sio.filePath(sid)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (sio *defaultStorageIO) Rename(oldSid, newSid string) error {
+	oldPath, err := sio.filePath(oldSid)
+	if err != nil {
+		return err
+	}
+	newPath, err := sio.filePath(newSid)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldPath, newPath)
 }
 
-func (sio *defaultStorageIO) List() (names []string) {
+func (sio *defaultStorageIO) List() ([]string, error) {
 	entries, err := os.ReadDir(sio.path)
 	if err != nil {
-		return
+		return nil, err
 	}
-	names = []string{}
+	names := []string{}
 	for _, entry := range entries {
-		names = append(names, strings.TrimPrefix(entry.Name(), sio.prefix))
+		name, ok := strings.CutPrefix(entry.Name(), sio.prefix)
+		if ok && entry.Type().IsRegular() {
+			names = append(names, name)
+		}
 	}
-	return
+	return names, nil
 }
 
-func (sio *defaultStorageIO) filePath(sid string) string {
-	return filepath.Join(sio.path, sio.prefix+sid)
+// Returns the session file path, or an error if the session identifier
+// would resolve it outside the sessions folder.
+func (sio *defaultStorageIO) filePath(sid string) (string, error) {
+	if sid == "" || strings.ContainsAny(sid, `/\`) {
+		return "", sessionpkg.ErrInvalidSessionId
+	}
+	path := filepath.Join(sio.path, sio.prefix+sid)
+	if filepath.Dir(path) != sio.path {
+		return "", sessionpkg.ErrInvalidSessionId
+	}
+	return path, nil
 }
 
 type storage struct {
-	io   storageIO
-	m    map[string]*list.Element
-	list *list.List
-	mu   sync.Mutex
+	io      storageIO
+	m       map[string]*list.Element
+	list    *list.List
+	users   map[string]map[string]struct{} // session ids by user id
+	metrics sessionpkg.Metrics
+	logger  *slog.Logger
+	skipped map[string]error // unreadable files not logged yet
+	mu      sync.Mutex
 }
 
 func newStorage(io storageIO) *storage {
 	s := &storage{
-		io:   io,
-		m:    map[string]*list.Element{},
-		list: list.New(),
-		mu:   sync.Mutex{},
+		io:    io,
+		m:     map[string]*list.Element{},
+		list:  list.New(),
+		users: map[string]map[string]struct{}{},
+		mu:    sync.Mutex{},
+	}
+	if io != nil {
+		s.load()
 	}
+	return s
+}
 
-	names := s.io.List()
-	if names == nil {
-		panic("cannot list sessions files")
+// Loads the sessions from the files, sorted by creation time. The files
+// that cannot be read are skipped, and logged through the storage logger,
+// as soon as it's set (see SetLogger()).
+func (s *storage) load() {
+	names, err := s.io.List()
+	if err != nil {
+		panic(fmt.Sprintf("cannot list sessions files, %v", err))
 	}
 
-	// load sessions from file system
-	var hold *list.Element
+	s.skipped = map[string]error{}
+	infos := make([]*basicSessionInfo, 0, len(names))
 	for _, name := range names {
 		sess, err := s.io.Read(name)
 		if err != nil {
-			panic("cannot load sessions files")
+			s.skipped[name] = err
+			continue
 		}
 		bsi := &basicSessionInfo{
 			sess.id,
 			sess.ct.UnixNano(),
+			sess.at.UnixNano(),
+			"",
 		}
-		for hold = s.list.Back(); hold != nil; hold = hold.Prev() {
-			hbsi := hold.Value.(*basicSessionInfo)
-			if bsi.ct >= hbsi.ct {
-				break
-			}
-			s.m[hbsi.id] = s.list.InsertAfter(hold.Value, hold)
-		}
-		if hold == nil {
-			s.m[bsi.id] = s.list.PushBack(bsi)
-		} else {
-			hold.Value = bsi
-			s.m[bsi.id] = hold
-		}
+		s.bind(bsi, sess.u)
+		infos = append(infos, bsi)
 	}
-	return s
+	slices.SortStableFunc(infos, func(a, b *basicSessionInfo) int {
+		return cmp.Compare(a.ct, b.ct)
+	})
+	for _, bsi := range infos {
+		s.m[bsi.id] = s.list.PushBack(bsi)
+	}
+	s.logSkipped()
+}
+
+// Logs the files skipped when loaded, if there's a logger. Must be called
+// holding the lock.
+func (s *storage) logSkipped() {
+	if s.logger == nil {
+		return
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.skipped)) {
+		s.logger.Warn("filesystem: skipped unreadable session file", "sid", sessionpkg.RedactID(name), "err", s.skipped[name])
+	}
+	s.skipped = nil
 }
 
 // Returns a session or an error if cannot creates a session and it's file.
-func (s *storage) CreateSession(sid string) (sessionpkg.Session, error) {
+func (s *storage) CreateSession(sid string) (_ sessionpkg.Session, err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observe("CreateSession", time.Now(), &err)
 
 	sess, err := s.io.Create(sid)
 	if err != nil {
@@ -272,17 +402,27 @@ func (s *storage) CreateSession(sid string) (sessionpkg.Session, error) {
 	s.m[sid] = s.list.PushBack(&basicSessionInfo{
 		sess.id,
 		sess.ct.UnixNano(),
+		sess.at.UnixNano(),
+		"",
 	})
 	return sess, nil
 }
 
 // Returns a session or an error if cannot reads the session from it's file.
-func (s *storage) GetSession(sid string) (sessionpkg.Session, error) {
+// Returns nil if the file no longer exists (e.g. removed by hand), which
+// is forgotten.
+func (s *storage) GetSession(sid string) (_ sessionpkg.Session, err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observe("GetSession", time.Now(), &err)
 
-	if _, ok := s.m[sid]; ok {
+	if elem, ok := s.m[sid]; ok {
 		sess, err := s.io.Read(sid)
+		if errors.Is(err, os.ErrNotExist) {
+			// removed outside the storage
+			s.forget(elem)
+			return nil, nil
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -295,75 +435,330 @@ func (s *storage) GetSession(sid string) (sessionpkg.Session, error) {
 func (s *storage) ContainsSession(sid string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observe("ContainsSession", time.Now(), nil)
 
 	_, ok := s.m[sid]
 	return ok, nil
 }
 
-// Destroys the session from the storage and it's file.
-func (s *storage) ReapSession(sid string) error {
+// Destroys the session from the storage and it's file, if it still exists.
+func (s *storage) ReapSession(sid string) (err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observe("ReapSession", time.Now(), &err)
 
 	if elem, ok := s.m[sid]; ok {
-		if err := s.io.Delete(sid); err != nil {
+		if err := s.io.Delete(sid); err != nil && !errors.Is(err, os.ErrNotExist) {
 			return err
 		}
-		s.list.Remove(elem)
-		delete(s.m, sid)
+		s.forget(elem)
 	}
 	return nil
 }
 
-// Scans the storage removing expired sessions.
-func (s *storage) Deadline(checker sessionpkg.AgeChecker) {
+// Moves the session file to a new session identifier, keeping it's
+// creation time. Returns nil if there's no session for the old one, or
+// an error if the new one is already in use.
+func (s *storage) RegenerateSession(oldSid, newSid string) (_ sessionpkg.Session, err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observe("RegenerateSession", time.Now(), &err)
 
-	for {
-		elem := s.list.Front()
-		if elem == nil {
-			break
-		}
+	elem, ok := s.m[oldSid]
+	if !ok {
+		return nil, nil
+	}
+	if _, ok := s.m[newSid]; ok {
+		return nil, sessionpkg.ErrDuplicatedSessionId
+	}
+	if err := s.io.Rename(oldSid, newSid); err != nil {
+		return nil, err
+	}
+	bsi := elem.Value.(*basicSessionInfo)
+	uid := bsi.u
+	s.unbind(bsi)
+	bsi.id = newSid
+	s.bind(bsi, uid)
+	delete(s.m, oldSid)
+	s.m[newSid] = elem
+	return s.io.Read(newSid)
+}
+
+// Updates the session access time, rewriting it's file.
+func (s *storage) TouchSession(sid string) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.observe("TouchSession", time.Now(), &err)
+
+	elem, ok := s.m[sid]
+	if !ok {
+		return nil
+	}
+	sess, err := s.io.Read(sid)
+	if err != nil {
+		return err
+	}
+	if err := s.io.Write(sess); err != nil {
+		return err
+	}
+	elem.Value.(*basicSessionInfo).at = sess.at.UnixNano()
+	return nil
+}
+
+// Scans the storage removing expired sessions and their files, returning
+// them. A file that no longer exists counts as removed. The sessions
+// which files cannot be removed are kept, and the failures are joined
+// into the returned error.
+//
+// Without idle checker, the scan stops at the first session that isn't
+// expired, since they're sorted by creation time.
+func (s *storage) Deadline(absolute, idle sessionpkg.AgeChecker) (_ []sessionpkg.ExpiredSession, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.observe("Deadline", time.Now(), &err)
+
+	var reaped []sessionpkg.ExpiredSession
+	var errs []error
+	for elem := s.list.Front(); elem != nil; {
 		bsi := elem.Value.(*basicSessionInfo)
-		if !checker.ShouldReap(time.Unix(0, bsi.ct)) {
-			break
+		next := elem.Next()
+		lifetime := sessionpkg.ExpiredLifetime(absolute, idle, time.Unix(0, bsi.ct), time.Unix(0, bsi.at))
+		if lifetime == "" {
+			if idle == nil {
+				break
+			}
+			elem = next
+			continue
 		}
-		if err := s.io.Delete(bsi.id); err == nil {
-			s.list.Remove(elem)
-			delete(s.m, bsi.id)
+		if err := s.io.Delete(bsi.id); err != nil && !errors.Is(err, os.ErrNotExist) {
+			if s.logger != nil {
+				s.logger.Error("filesystem: unable to remove expired session file", "sid", sessionpkg.RedactID(bsi.id), "err", err)
+			}
+			errs = append(errs, err)
+		} else {
+			reaped = append(reaped, sessionpkg.ExpiredSession{SessionInfo: bsi.info(), Lifetime: lifetime})
+			s.forget(elem)
 		}
+		elem = next
 	}
+	return reaped, errors.Join(errs...)
 }
 
-func (s *storage) update(sess *session) error {
+func (s *storage) update(sess *session) (err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observe("UpdateSession", time.Now(), &err)
 
-	if _, ok := s.m[sess.id]; ok {
+	if elem, ok := s.m[sess.id]; ok {
+		bsi := elem.Value.(*basicSessionInfo)
+		// the index is up to date, even if the session was read before
+		// it was bound
+		sess.u = bsi.u
 		if err := s.io.Write(sess); err != nil {
 			return err
 		}
+		bsi.at = sess.at.UnixNano()
 	}
 	return nil
 }
 
+// Binds the session to the user identifier, or unbinds it when uid is
+// empty, rewriting it's file. When the user would hold more sessions than
+// the limit, the oldest ones and their files are removed and returned, or
+// an error is returned if the limit doesn't evict them. Returns an error
+// if the session doesn't exist.
+func (s *storage) BindUser(sid, uid string, limit sessionpkg.UserLimit) (_ []string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.observe("BindUser", time.Now(), &err)
+
+	elem, ok := s.m[sid]
+	if !ok {
+		return nil, sessionpkg.ErrUnknownSessionId
+	}
+	bsi := elem.Value.(*basicSessionInfo)
+	var evicted []string
+	if limit.Max > 0 && uid != "" && bsi.u != uid {
+		var held []*basicSessionInfo
+		for id := range s.users[uid] {
+			held = append(held, s.m[id].Value.(*basicSessionInfo))
+		}
+		if n := len(held) - limit.Max + 1; n > 0 {
+			if !limit.EvictOldest {
+				return nil, &sessionpkg.SessionLimitError{UID: uid, Max: limit.Max}
+			}
+			slices.SortFunc(held, func(a, b *basicSessionInfo) int {
+				return cmp.Compare(a.ct, b.ct)
+			})
+			for _, old := range held[:n] {
+				if err := s.io.Delete(old.id); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return evicted, err
+				}
+				evicted = append(evicted, old.id)
+				s.forget(s.m[old.id])
+			}
+		}
+	}
+	sess, err := s.io.Read(sid)
+	if errors.Is(err, os.ErrNotExist) {
+		s.forget(elem)
+		return evicted, sessionpkg.ErrUnknownSessionId
+	}
+	if err != nil {
+		return evicted, err
+	}
+	sess.u = uid
+	if err := s.io.Write(sess); err != nil {
+		return evicted, err
+	}
+	bsi.at = sess.at.UnixNano()
+	s.unbind(bsi)
+	s.bind(bsi, uid)
+	return evicted, nil
+}
+
+// Returns the identifiers of the sessions bound to the user, sorted.
+func (s *storage) UserSessions(uid string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.observe("UserSessions", time.Now(), nil)
+
+	return slices.Sorted(maps.Keys(s.users[uid])), nil
+}
+
+// Returns the sessions kept by the storage, sorted by creation time,
+// without reading their files.
+func (s *storage) ListSessions() ([]sessionpkg.SessionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.observe("ListSessions", time.Now(), nil)
+
+	infos := make([]sessionpkg.SessionInfo, 0, len(s.m))
+	for elem := s.list.Front(); elem != nil; elem = elem.Next() {
+		infos = append(infos, elem.Value.(*basicSessionInfo).info())
+	}
+	return infos, nil
+}
+
+// Sets the Metrics that the operations and the number of sessions are
+// reported to. Setting nil stops reporting.
+//
+// Besides the Storage methods, the session file writes done by Set() and
+// Delete() are reported as "UpdateSession".
+func (s *storage) SetMetrics(m sessionpkg.Metrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = m
+	if m != nil {
+		m.LiveSessions(len(s.m))
+	}
+}
+
+// Sets the logger, which reports the session files that cannot be
+// removed, and the ones skipped when loaded. Setting nil stops logging,
+// which is the default.
+func (s *storage) SetLogger(l *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = l
+	s.logSkipped()
+}
+
+// Reports the operation, started at the given time, and the number of
+// sessions. Must be called holding the lock.
+func (s *storage) observe(op string, start time.Time, err *error) {
+	if s.metrics == nil {
+		return
+	}
+	var e error
+	if err != nil {
+		e = *err
+	}
+	s.metrics.StorageOperation(op, time.Since(start), e)
+	s.metrics.LiveSessions(len(s.m))
+}
+
+func (s *storage) bind(bsi *basicSessionInfo, uid string) {
+	bsi.u = uid
+	if uid == "" {
+		return
+	}
+	if s.users == nil {
+		s.users = map[string]map[string]struct{}{}
+	}
+	if s.users[uid] == nil {
+		s.users[uid] = map[string]struct{}{}
+	}
+	s.users[uid][bsi.id] = struct{}{}
+}
+
+func (s *storage) unbind(bsi *basicSessionInfo) {
+	if bsi.u == "" {
+		return
+	}
+	delete(s.users[bsi.u], bsi.id)
+	if len(s.users[bsi.u]) == 0 {
+		delete(s.users, bsi.u)
+	}
+	bsi.u = ""
+}
+
+// Removes the session from the index, after it's file is removed.
+func (s *storage) forget(elem *list.Element) {
+	bsi := elem.Value.(*basicSessionInfo)
+	s.unbind(bsi)
+	s.list.Remove(elem)
+	delete(s.m, bsi.id)
+}
+
 func (s *storage) setIO(io storageIO) {
 	s.m = map[string]*list.Element{}
+	s.users = map[string]map[string]struct{}{}
 	s.list.Init()
 	s.io = io
+	s.load()
 }
 
-var _io = newStorageIO(filepath.Join(os.TempDir(), "gosessions"))
-var _storage = newStorage(_io)
+// Reads every session file, including the ones skipped when loaded,
+// returning the errors of the ones that cannot be read (e.g. corrupted)
+// by session identifier. Returns an error if the files cannot be listed.
+func (s *storage) Verify() (map[string]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names, err := s.io.List()
+	if err != nil {
+		return nil, err
+	}
+	errs := map[string]error{}
+	for _, name := range names {
+		if _, err := s.io.Read(name); err != nil {
+			errs[name] = err
+		}
+	}
+	return errs, nil
+}
+
+// The storage returned by Storage(), without io until it's first called,
+// so importing the package doesn't touch the filesystem.
+var _storage = newStorage(nil)
 
 // Returns the storage.
 //
 // It's possible to set the path where the sessions files will
 // be created. To do this, just call this function giving a
-// valid string path. Keep in mind that all sessions will be lost.
+// valid string path. The sessions are loaded from the files
+// already in the path, while the previous ones are forgotten.
+//
+// Without a path, the first call sets it to "gosessions" under the
+// os.TempDir() (creating it, if needed), unless a path was already set.
 func Storage(args ...string) *storage {
 	if len(args) == 0 {
+		_storage.mu.Lock()
+		defer _storage.mu.Unlock()
+		if _storage.io == nil {
+			_storage.setIO(newStorageIO(filepath.Join(os.TempDir(), "gosessions")))
+		}
 		return _storage
 	}
 	path := args[0]
@@ -380,4 +775,5 @@ func Storage(args ...string) *storage {
 
 func init() {
 	gob.Register(map[string]any{})
+	gob.Register([]any{})
 }
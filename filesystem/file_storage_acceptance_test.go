@@ -82,7 +82,7 @@ func TestSessionLifecycleAtStorage(t *testing.T) {
 
 		time.Sleep(time.Second + 1)
 
-		storage.Deadline(session.SecondsAgeCheckerAdapter(1))
+		storage.Deadline(session.SecondsAgeCheckerAdapter(1), nil)
 
 		sess, err = storage.GetSession(sess.SessionID())
 		assert.NoError(t, err)
@@ -94,3 +94,42 @@ func TestSessionLifecycleAtStorage(t *testing.T) {
 		}
 	})
 }
+
+func TestFlashesAtStorage(t *testing.T) {
+	path := "sessions_from_flashes_acceptance_test"
+	storage := filesystem.Storage(path)
+	sid := "a63140d2bb051e439c790a4d35c1"
+	t.Cleanup(func() {
+		if err := os.RemoveAll(path); err != nil {
+			log.Fatalf("cannot clean up after test, %v", err)
+		}
+	})
+
+	sess, err := storage.CreateSession(sid)
+	assert.NoError(t, err)
+
+	type message struct {
+		Text  string
+		Level int
+	}
+	err = session.Flash(sess, "info", message{"saved", 1})
+	assert.NoError(t, err)
+	err = session.Flash(sess, "info", "done")
+	assert.NoError(t, err)
+
+	// reread session from the file, like the next request
+	sess, _ = storage.GetSession(sid)
+
+	t.Run("read flashes", func(t *testing.T) {
+		got := session.Flashes(sess, "info")
+
+		assert.Equal(t, len(got), 2)
+		assert.Equal(t, got[0].(map[string]any)["Text"], any("saved"))
+		assert.Equal(t, got[1], any("done"))
+	})
+	// reread session from the file, like the next request
+	sess, _ = storage.GetSession(sid)
+	t.Run("flashes were removed after read", func(t *testing.T) {
+		assert.Equal(t, len(session.Flashes(sess, "info")), 0)
+	})
+}
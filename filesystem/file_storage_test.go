@@ -1,17 +1,22 @@
 package filesystem
 
 import (
+	"bytes"
 	"container/list"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"reflect"
 	"slices"
-	"sync"
+	"strings"
 	"testing"
 	"time"
 
+	sessionpkg "github.com/xandalm/go-session"
 	"github.com/xandalm/go-session/testing/assert"
 )
 
@@ -67,12 +72,10 @@ func TestSetValueFromSession(t *testing.T) {
 			sess.v,
 			sess.ct.UnixNano(),
 			sess.at.UnixNano(),
+			sess.u,
 		},
 	}}
-	_storage.io = io
-	t.Cleanup(func() {
-		_storage.io = _io // default io
-	})
+	useStorageIO(t, io)
 
 	cases := []struct {
 		typ   string
@@ -83,6 +86,15 @@ func TestSetValueFromSession(t *testing.T) {
 		{"int", 1, 1},
 		{"struct", struct{ Id int }{10}, map[string]any{"Id": 10}},
 		{"map[string]int", map[string]int{"a": 1}, map[string]any{"a": 1}},
+		{"map[string]any", map[string]any{"a": struct{ Id int }{1}}, map[string]any{"a": map[string]any{"Id": 1}}},
+		{"[]string", []string{"a"}, []string{"a"}},
+		{"[]struct", []struct{ Id int }{{10}}, []any{map[string]any{"Id": 10}}},
+		{"[]any", []any{"a", struct{ Id int }{10}}, []any{"a", map[string]any{"Id": 10}}},
+		{"struct with unexported field", struct {
+			Id   int
+			name string
+		}{10, "a"}, map[string]any{"Id": 10}},
+		{"struct with pointer field", struct{ Id *int }{}, map[string]any{"Id": nil}},
 	}
 
 	for _, c := range cases {
@@ -140,12 +152,10 @@ func TestDeleteValueFromSession(t *testing.T) {
 			sess.v,
 			sess.ct.UnixNano(),
 			sess.at.UnixNano(),
+			sess.u,
 		},
 	}}
-	_storage.io = io
-	t.Cleanup(func() {
-		_storage.io = _io // default io
-	})
+	useStorageIO(t, io)
 	err := sess.Delete("key")
 
 	assert.NoError(t, err)
@@ -164,12 +174,13 @@ type stubStorageIO struct {
 
 func (sio *stubStorageIO) Create(sid string) (*session, error) {
 	now := time.Now()
-	sess := &session{sid, map[string]any{}, now, now}
+	sess := &session{id: sid, v: map[string]any{}, ct: now, at: now}
 
 	sio.regs[sid] = &extSession{
 		sess.v,
 		sess.ct.UnixNano(),
 		sess.at.UnixNano(),
+		sess.u,
 	}
 
 	return sess, nil
@@ -178,10 +189,11 @@ func (sio *stubStorageIO) Create(sid string) (*session, error) {
 func (sio *stubStorageIO) Read(sid string) (*session, error) {
 	if reg, ok := sio.regs[sid]; ok {
 		return &session{
-			sid,
-			reg.V,
-			time.Unix(0, reg.Ct),
-			time.Unix(0, reg.At),
+			id: sid,
+			v:  reg.V,
+			ct: time.Unix(0, reg.Ct),
+			at: time.Unix(0, reg.At),
+			u:  reg.U,
 		}, nil
 	}
 
@@ -190,8 +202,10 @@ func (sio *stubStorageIO) Read(sid string) (*session, error) {
 
 func (sio *stubStorageIO) Write(sess *session) error {
 	esess := sio.regs[sess.id]
-	esess.At = time.Now().UnixNano()
+	sess.at = time.Now()
+	esess.At = sess.at.UnixNano()
 	esess.V = sess.v
+	esess.U = sess.u
 	sio.regs[sess.id] = esess
 	return nil
 }
@@ -201,7 +215,13 @@ func (sio *stubStorageIO) Delete(sid string) error {
 	return nil
 }
 
-func (sio *stubStorageIO) List() []string {
+func (sio *stubStorageIO) Rename(oldSid, newSid string) error {
+	sio.regs[newSid] = sio.regs[oldSid]
+	delete(sio.regs, oldSid)
+	return nil
+}
+
+func (sio *stubStorageIO) List() ([]string, error) {
 	names := make([]string, len(sio.regs))
 	var x, y int
 	for name, reg := range sio.regs {
@@ -214,7 +234,7 @@ func (sio *stubStorageIO) List() []string {
 		names[x+1] = name
 		y++
 	}
-	return names
+	return names, nil
 }
 
 var dummyMap = map[string]*list.Element{}
@@ -224,10 +244,9 @@ func TestCreatingSessionInStorage(t *testing.T) {
 	t.Run("create session", func(t *testing.T) {
 		io := &stubStorageIO{regs: map[string]*extSession{}}
 		storage := &storage{
-			io,
-			dummyMap,
-			dummyList,
-			sync.Mutex{},
+			io:   io,
+			m:    dummyMap,
+			list: dummyList,
 		}
 
 		sid := "abcde"
@@ -255,10 +274,10 @@ func TestGettingSessionFromStorage(t *testing.T) {
 		sid := "abcde"
 
 		sess := &session{
-			sid,
-			map[string]any{},
-			time.Now(),
-			time.Now(),
+			id: sid,
+			v:  map[string]any{},
+			ct: time.Now(),
+			at: time.Now(),
 		}
 		m, l := createSessionsMapAndList(sess)
 		storage := &storage{
@@ -292,10 +311,10 @@ func TestReapingSessionFromStorage(t *testing.T) {
 		sid := "abcde"
 
 		sess := &session{
-			sid,
-			map[string]any{},
-			time.Now(),
-			time.Now(),
+			id: sid,
+			v:  map[string]any{},
+			ct: time.Now(),
+			at: time.Now(),
 		}
 		m, l := createSessionsMapAndList(sess)
 		io := &stubStorageIO{
@@ -319,26 +338,68 @@ func TestReapingSessionFromStorage(t *testing.T) {
 	})
 }
 
+func TestRegeneratingSessionInStorage(t *testing.T) {
+	t.Run("moves session to new id", func(t *testing.T) {
+
+		sess := &session{
+			id: "abcde",
+			v:  map[string]any{"foo": "bar"},
+			ct: time.Now(),
+			at: time.Now(),
+		}
+		m, l := createSessionsMapAndList(sess)
+		io := &stubStorageIO{
+			regs: map[string]*extSession{
+				sess.id: createExtSessionFromSession(sess),
+			},
+		}
+		storage := &storage{
+			io:   io,
+			m:    m,
+			list: l,
+		}
+
+		got, err := storage.RegenerateSession("abcde", "fghij")
+
+		assert.NoError(t, err)
+		assert.NotNil(t, got)
+		assert.Equal(t, got.SessionID(), "fghij")
+		assert.Equal(t, got.Get("foo"), any("bar"))
+
+		if _, ok := io.regs["abcde"]; ok {
+			t.Error("didn't remove old session")
+		}
+		if _, ok := storage.m["abcde"]; ok {
+			t.Error("didn't remove old session from index")
+		}
+		if elem, ok := storage.m["fghij"]; !ok || elem.Value.(*basicSessionInfo).id != "fghij" {
+			t.Error("didn't index new session")
+		}
+	})
+}
+
 func TestDeadlineCheckUpInStorage(t *testing.T) {
 	t.Run("remove expired session", func(t *testing.T) {
 
 		regs := map[string]*extSession{}
-		sess1 := &session{"1", map[string]any{}, time.Now(), time.Now()}
+		sess1 := &session{id: "1", v: map[string]any{}, ct: time.Now(), at: time.Now()}
 		regs[sess1.id] = createExtSessionFromSession(sess1)
-		sess2 := &session{"2", map[string]any{}, time.Now(), time.Now()}
+		sess2 := &session{id: "2", v: map[string]any{}, ct: time.Now(), at: time.Now()}
 		regs[sess2.id] = createExtSessionFromSession(sess2)
 
 		time.Sleep(10 * time.Millisecond)
 
-		sess3 := &session{"3", map[string]any{}, time.Now(), time.Now()}
+		sess3 := &session{id: "3", v: map[string]any{}, ct: time.Now(), at: time.Now()}
 		regs[sess3.id] = createExtSessionFromSession(sess3)
 
 		io := &stubStorageIO{regs: regs}
 		m, l := createSessionsMapAndList(sess1, sess2, sess3)
-		storage := &storage{io, m, l, sync.Mutex{}}
+		storage := &storage{io: io, m: m, list: l}
 
-		storage.Deadline(stubMilliAgeChecker(10))
+		reaped, err := storage.Deadline(stubMilliAgeChecker(10), nil)
 
+		assert.NoError(t, err)
+		assert.Equal(t, len(reaped), 2)
 		if len(io.regs) > 1 {
 			t.Fatalf("didn't remove expired sessions, got %d/3", len(io.regs))
 		}
@@ -346,6 +407,90 @@ func TestDeadlineCheckUpInStorage(t *testing.T) {
 			t.Errorf("session %v must be in the storage", regs["3"])
 		}
 	})
+	t.Run("keep session which file cannot be removed", func(t *testing.T) {
+
+		regs := map[string]*extSession{}
+		sess1 := &session{id: "1", v: map[string]any{}, ct: time.Now(), at: time.Now()}
+		regs[sess1.id] = createExtSessionFromSession(sess1)
+		sess2 := &session{id: "2", v: map[string]any{}, ct: time.Now(), at: time.Now()}
+		regs[sess2.id] = createExtSessionFromSession(sess2)
+
+		time.Sleep(10 * time.Millisecond)
+
+		io := &stubFailingDeleteStorageIO{stubStorageIO{regs: regs}, "1"}
+		m, l := createSessionsMapAndList(sess1, sess2)
+		storage := &storage{io: io, m: m, list: l}
+		want := []sessionpkg.ExpiredSession{
+			{SessionInfo: m["2"].Value.(*basicSessionInfo).info(), Lifetime: sessionpkg.LifetimeMaxAge},
+		}
+
+		reaped, err := storage.Deadline(stubMilliAgeChecker(10), nil)
+
+		assert.Error(t, err)
+		assert.Equal(t, reaped, want)
+		if _, ok := storage.m["1"]; !ok {
+			t.Error("expected session to be kept")
+		}
+		if _, ok := io.regs["2"]; ok {
+			t.Error("didn't remove the other expired session")
+		}
+	})
+	t.Run("remove idle session", func(t *testing.T) {
+
+		regs := map[string]*extSession{}
+		sess1 := &session{id: "1", v: map[string]any{}, ct: time.Now(), at: time.Now().Add(-time.Second)}
+		regs[sess1.id] = createExtSessionFromSession(sess1)
+		sess2 := &session{id: "2", v: map[string]any{}, ct: time.Now(), at: time.Now()}
+		regs[sess2.id] = createExtSessionFromSession(sess2)
+
+		io := &stubStorageIO{regs: regs}
+		m, l := createSessionsMapAndList(sess1, sess2)
+		storage := &storage{io: io, m: m, list: l}
+		want := []sessionpkg.ExpiredSession{
+			{SessionInfo: m["1"].Value.(*basicSessionInfo).info(), Lifetime: sessionpkg.LifetimeIdleTimeout},
+		}
+
+		reaped, err := storage.Deadline(stubMilliAgeChecker(5000), stubMilliAgeChecker(500))
+
+		assert.NoError(t, err)
+		assert.Equal(t, reaped, want)
+		if _, ok := io.regs["2"]; !ok {
+			t.Errorf("session %v must be in the storage", regs["2"])
+		}
+	})
+}
+
+func TestTouchingSessionInStorage(t *testing.T) {
+	regs := map[string]*extSession{}
+	sess := &session{id: "1", v: map[string]any{}, ct: time.Now(), at: time.Now().Add(-time.Second)}
+	regs[sess.id] = createExtSessionFromSession(sess)
+
+	io := &stubStorageIO{regs: regs}
+	m, l := createSessionsMapAndList(sess)
+	storage := &storage{io: io, m: m, list: l}
+
+	err := storage.TouchSession("1")
+
+	assert.NoError(t, err)
+	at := time.Unix(0, storage.m["1"].Value.(*basicSessionInfo).at)
+	if time.Since(at) > 100*time.Millisecond {
+		t.Errorf("didn't update access time in index, got %v", at)
+	}
+	if time.Since(time.Unix(0, io.regs["1"].At)) > 100*time.Millisecond {
+		t.Error("didn't update access time in file")
+	}
+}
+
+type stubFailingDeleteStorageIO struct {
+	stubStorageIO
+	failing string
+}
+
+func (sio *stubFailingDeleteStorageIO) Delete(sid string) error {
+	if sid == sio.failing {
+		return errors.New("cannot remove file")
+	}
+	return sio.stubStorageIO.Delete(sid)
 }
 
 func createExtSessionFromSession(v *session) *extSession {
@@ -353,6 +498,7 @@ func createExtSessionFromSession(v *session) *extSession {
 		v.v,
 		v.ct.UnixNano(),
 		v.at.UnixNano(),
+		v.u,
 	}
 }
 
@@ -363,6 +509,8 @@ func createSessionsMapAndList(v ...*session) (m map[string]*list.Element, l *lis
 		m[s.id] = l.PushBack(&basicSessionInfo{
 			s.id,
 			s.ct.UnixNano(),
+			s.at.UnixNano(),
+			s.u,
 		})
 	}
 	return
@@ -374,6 +522,162 @@ func (c stubMilliAgeChecker) ShouldReap(t time.Time) bool {
 	return time.Now().UnixMilli()-t.UnixMilli() >= int64(c)
 }
 
+func TestUserIndexInStorage(t *testing.T) {
+	regs := map[string]*extSession{}
+	for i, uid := range []string{"alex", "alex", ""} {
+		sess := &session{id: fmt.Sprint(i + 1), v: map[string]any{}, ct: time.Now(), at: time.Now(), u: uid}
+		regs[sess.id] = createExtSessionFromSession(sess)
+	}
+	io := &stubStorageIO{regs: regs}
+	storage := newStorage(io)
+
+	t.Run("loads the user sessions", func(t *testing.T) {
+		got, err := storage.UserSessions("alex")
+
+		assert.NoError(t, err)
+		assert.Equal(t, got, []string{"1", "2"})
+		assert.Equal(t, storage.list.Len(), 3)
+	})
+	t.Run("binds session to user", func(t *testing.T) {
+		stale, _ := storage.GetSession("3")
+
+		_, err := storage.BindUser("3", "andre", sessionpkg.UserLimit{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, io.regs["3"].U, "andre")
+		got, _ := storage.UserSessions("andre")
+		assert.Equal(t, got, []string{"3"})
+
+		t.Run("and keeps it when session read before is updated", func(t *testing.T) {
+			err := storage.update(stale.(*session))
+
+			assert.NoError(t, err)
+			assert.Equal(t, io.regs["3"].U, "andre")
+		})
+	})
+	t.Run("returns error for unknown session", func(t *testing.T) {
+		_, err := storage.BindUser("9", "alex", sessionpkg.UserLimit{})
+
+		assert.Equal(t, err, sessionpkg.ErrUnknownSessionId)
+	})
+	t.Run("moves the binding of regenerated session", func(t *testing.T) {
+		storage.RegenerateSession("2", "4")
+
+		got, _ := storage.UserSessions("alex")
+		assert.Equal(t, got, []string{"1", "4"})
+	})
+	t.Run("unbinds reaped and expired sessions", func(t *testing.T) {
+		storage.ReapSession("1")
+		io.regs["4"].Ct = time.Now().Add(-time.Second).UnixNano()
+		storage.m["4"].Value.(*basicSessionInfo).ct = io.regs["4"].Ct
+
+		storage.Deadline(stubMilliAgeChecker(500), nil)
+
+		got, _ := storage.UserSessions("alex")
+		assert.Equal(t, len(got), 0)
+		assert.Equal(t, len(storage.users), 1)
+	})
+}
+
+func TestUserLimitInStorage(t *testing.T) {
+	regs := map[string]*extSession{}
+	now := time.Now()
+	for i, sid := range []string{"2", "1", "3", "4"} {
+		uid := "alex"
+		if sid == "4" {
+			uid = ""
+		}
+		sess := &session{id: sid, v: map[string]any{}, ct: now.Add(time.Duration(i) * time.Second), at: now, u: uid}
+		regs[sess.id] = createExtSessionFromSession(sess)
+	}
+
+	t.Run("rejects session over the limit", func(t *testing.T) {
+		storage := newStorage(&stubStorageIO{regs: maps.Clone(regs)})
+
+		_, err := storage.BindUser("4", "alex", sessionpkg.UserLimit{Max: 3})
+
+		if !errors.Is(err, sessionpkg.ErrSessionLimit) {
+			t.Errorf("didn't return limit error, got %v", err)
+		}
+	})
+	t.Run("evicts the oldest sessions and their files", func(t *testing.T) {
+		io := &stubStorageIO{regs: maps.Clone(regs)}
+		storage := newStorage(io)
+
+		evicted, err := storage.BindUser("4", "alex", sessionpkg.UserLimit{Max: 2, EvictOldest: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, evicted, []string{"2", "1"})
+		got, _ := storage.UserSessions("alex")
+		assert.Equal(t, got, []string{"3", "4"})
+		if _, ok := io.regs["2"]; ok {
+			t.Error("didn't remove evicted session file")
+		}
+		assert.Equal(t, storage.list.Len(), 2)
+	})
+}
+
+func TestMissingSessionFileInStorage(t *testing.T) {
+	newStorageWithMissingFile := func(t *testing.T) *storage {
+		t.Helper()
+		io := newStorageIO(t.TempDir())
+		storage := newStorage(io)
+		for _, sid := range []string{"1", "2"} {
+			_, err := storage.CreateSession(sid)
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, io.Delete("1"))
+		return storage
+	}
+
+	t.Run("returns nil and forgets the session", func(t *testing.T) {
+		storage := newStorageWithMissingFile(t)
+
+		sess, err := storage.GetSession("1")
+
+		assert.NoError(t, err)
+		assert.Nil(t, sess)
+		ok, _ := storage.ContainsSession("1")
+		assert.Equal(t, ok, false)
+	})
+	t.Run("reaps the session", func(t *testing.T) {
+		storage := newStorageWithMissingFile(t)
+
+		err := storage.ReapSession("1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, storage.list.Len(), 1)
+	})
+	t.Run("reports the expired session", func(t *testing.T) {
+		storage := newStorageWithMissingFile(t)
+		time.Sleep(10 * time.Millisecond)
+
+		reaped, err := storage.Deadline(stubMilliAgeChecker(10), nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, len(reaped), 2)
+		assert.Equal(t, storage.list.Len(), 0)
+	})
+	t.Run("evicts the session", func(t *testing.T) {
+		storage := newStorageWithMissingFile(t)
+		_, err := storage.BindUser("1", "alex", sessionpkg.UserLimit{})
+		if !errors.Is(err, sessionpkg.ErrUnknownSessionId) {
+			t.Fatalf("didn't return unknown session error, got %v", err)
+		}
+		storage.bind(storage.m["2"].Value.(*basicSessionInfo), "alex")
+		_, err = storage.CreateSession("3")
+		assert.NoError(t, err)
+		storage.io.Delete("2")
+
+		evicted, err := storage.BindUser("3", "alex", sessionpkg.UserLimit{Max: 1, EvictOldest: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, evicted, []string{"2"})
+		got, _ := storage.UserSessions("alex")
+		assert.Equal(t, got, []string{"3"})
+	})
+}
+
 func TestDefaultStorageIO(t *testing.T) {
 	path := "sessions_from_test"
 
@@ -447,14 +751,37 @@ func TestDefaultStorageIO(t *testing.T) {
 			t.Error("the session file still exists")
 		}
 	})
+	t.Run("renames session file", func(t *testing.T) {
+		_, err := io.Create("abcde")
+		assert.NoError(t, err)
+
+		err = io.Rename("abcde", "fghij")
+		assert.NoError(t, err)
+
+		sess, err := io.Read("fghij")
+		assert.NoError(t, err)
+		assert.NotNil(t, sess)
+
+		if _, err := io.Read("abcde"); err == nil {
+			t.Error("the old session file still exists")
+		}
+		assert.NoError(t, io.Delete("fghij"))
+	})
+	t.Run("refuses session id outside the sessions folder", func(t *testing.T) {
+		for _, sid := range []string{"../abcde", "/../../abcde", `..\abcde`, "a/b", ""} {
+			_, err := io.Create(sid)
+			assert.Equal(t, err, sessionpkg.ErrInvalidSessionId, "expected error for sid %q, got %v", sid, err)
+		}
+	})
 	t.Run("list sessions name asc sorted by creation time", func(t *testing.T) {
 
 		sess1, _ := io.Create("abcde")
 		sess2, _ := io.Create("fghij")
 		sess3, _ := io.Create("klmno")
 
-		got := io.List()
+		got, err := io.List()
 
+		assert.NoError(t, err)
 		assert.NotNil(t, got)
 
 		if len(got) != 3 {
@@ -473,6 +800,25 @@ func TestDefaultStorageIO(t *testing.T) {
 			t.Errorf("expected %v to contains %q", got, sess3.id)
 		}
 	})
+	t.Run("lists only the session files", func(t *testing.T) {
+		os.WriteFile(filepath.Join(io.path, "notes.txt"), nil, 0666)
+		os.Mkdir(filepath.Join(io.path, io.prefix+"dir"), 0750)
+
+		got, _ := io.List()
+
+		if len(got) != 3 {
+			t.Errorf("expected 3 sessions, got %v", got)
+		}
+	})
+	t.Run("returns error when the folder cannot be read", func(t *testing.T) {
+		missing := &defaultStorageIO{filepath.Join(t.TempDir(), "missing"), io.prefix}
+
+		_, err := missing.List()
+
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("didn't return the folder error, got %v", err)
+		}
+	})
 
 	t.Cleanup(func() {
 		if err := os.RemoveAll(io.path); err != nil {
@@ -484,3 +830,186 @@ func TestDefaultStorageIO(t *testing.T) {
 func writeSessionToString(sess *session) string {
 	return fmt.Sprintf("{id=%s, creationtime=%s, values=%+v}", sess.id, sess.ct, sess.v)
 }
+
+type spyMetrics struct {
+	sessionpkg.NopMetrics
+	ops  []string
+	errs int
+	live int
+}
+
+func (m *spyMetrics) LiveSessions(n int) {
+	m.live = n
+}
+
+func (m *spyMetrics) StorageOperation(op string, d time.Duration, err error) {
+	m.ops = append(m.ops, op)
+	if err != nil {
+		m.errs++
+	}
+}
+
+func TestMetricsInStorage(t *testing.T) {
+	io := &stubStorageIO{regs: map[string]*extSession{}}
+	storage := newStorage(io)
+	metrics := &spyMetrics{}
+	storage.SetMetrics(metrics)
+
+	storage.CreateSession("abcde")
+	storage.CreateSession("fghij")
+	storage.RegenerateSession("abcde", "fghij")
+	storage.ReapSession("fghij")
+
+	assert.Equal(t, metrics.live, 1)
+	assert.Equal(t, len(metrics.ops), 4)
+	assert.Equal(t, metrics.ops[2], "RegenerateSession")
+	assert.Equal(t, metrics.errs, 1)
+}
+
+type stubUnreadableStorageIO struct {
+	*stubStorageIO
+	unreadable string
+}
+
+func (sio *stubUnreadableStorageIO) List() ([]string, error) {
+	names, _ := sio.stubStorageIO.List()
+	return append(names, sio.unreadable), nil
+}
+
+func (sio *stubUnreadableStorageIO) Read(sid string) (*session, error) {
+	if sid == sio.unreadable {
+		return nil, errors.New("unexpected EOF")
+	}
+	return sio.stubStorageIO.Read(sid)
+}
+
+func TestLoadingStorage(t *testing.T) {
+	io := &stubUnreadableStorageIO{
+		stubStorageIO: &stubStorageIO{regs: map[string]*extSession{
+			"abcde": {map[string]any{}, time.Now().UnixNano(), time.Now().UnixNano(), ""},
+		}},
+		unreadable: "fghij",
+	}
+
+	storage := newStorage(io)
+
+	if ok, _ := storage.ContainsSession("abcde"); !ok {
+		t.Error("didn't load the session")
+	}
+	if ok, _ := storage.ContainsSession("fghij"); ok {
+		t.Error("didn't skip the unreadable session file")
+	}
+
+	t.Run("logs the skipped files once the logger is set", func(t *testing.T) {
+		var buf bytes.Buffer
+		storage.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+
+		out := buf.String()
+		if !strings.Contains(out, "skipped unreadable session file") || !strings.Contains(out, sessionpkg.RedactID("fghij")) {
+			t.Errorf("didn't log the skipped file, got %q", out)
+		}
+	})
+}
+
+func TestListingSessionsInStorage(t *testing.T) {
+	now := time.Now()
+	io := &stubStorageIO{regs: map[string]*extSession{
+		"fghij": {map[string]any{"foo": 1, "bar": 2}, now.UnixNano(), now.UnixNano(), "alex"},
+		"abcde": {map[string]any{}, now.Add(-time.Second).UnixNano(), now.UnixNano(), ""},
+	}}
+	storage := newStorage(io)
+
+	got, err := storage.ListSessions()
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got[0].ID, "abcde")
+	assert.Equal(t, got[1].ID, "fghij")
+	assert.Equal(t, got[1].UID, "alex")
+	assert.Equal(t, got[1].Created.UnixNano(), now.UnixNano())
+
+	sess, _ := storage.GetSession("fghij")
+	assert.Equal(t, sess.(*session).Keys(), []string{"bar", "foo"})
+}
+
+func TestSettingPathOfStorage(t *testing.T) {
+	path := t.TempDir()
+	sio := newStorageIO(path)
+	sio.Create("abcde")
+	os.WriteFile(filepath.Join(sio.path, sio.prefix+"fghij"), []byte("corrupted"), 0666)
+
+	storage := newStorage(&stubStorageIO{regs: map[string]*extSession{}})
+	storage.setIO(sio)
+
+	if ok, _ := storage.ContainsSession("abcde"); !ok {
+		t.Error("didn't load the sessions from the path")
+	}
+	errs, err := storage.Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, len(errs), 1)
+	if errs["fghij"] == nil {
+		t.Errorf("didn't report the corrupted file, got %v", errs)
+	}
+}
+
+func TestGettingValueAsFromSession(t *testing.T) {
+	type address struct {
+		City string
+	}
+	type user struct {
+		Name      string
+		Addresses []address
+		Manager   *address
+	}
+
+	sess := &session{
+		id: "abcde",
+		v:  map[string]any{},
+		ct: time.Now(),
+	}
+	io := &stubStorageIO{regs: map[string]*extSession{
+		sess.id: {sess.v, sess.ct.UnixNano(), sess.at.UnixNano(), sess.u},
+	}}
+	useStorageIO(t, io)
+
+	want := user{"foo", []address{{"bar"}}, &address{"baz"}}
+	err := sess.Set("user", want)
+	assert.NoError(t, err)
+
+	got, ok, err := sessionpkg.GetAs[user](sess, "user")
+
+	assert.NoError(t, err)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, got, want)
+}
+
+func TestReadingSessionTimesWhileSetting(t *testing.T) {
+	useStorageIO(t, &stubStorageIO{regs: map[string]*extSession{}})
+	sess, err := _storage.CreateSession("abcde")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_storage.ReapSession("abcde")
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 100 {
+			sess.Set("foo", i)
+		}
+	}()
+	for range 100 {
+		sess.CreationTime()
+		sess.AccessTime()
+	}
+	<-done
+}
+
+// Sets the io of the default storage until the test ends.
+func useStorageIO(t *testing.T, io storageIO) {
+	prev := _storage.io
+	_storage.io = io
+	t.Cleanup(func() {
+		_storage.io = prev
+	})
+}
@@ -0,0 +1,62 @@
+package session
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Prefix of the session keys holding flash messages, which shouldn't be
+// used for other values.
+const FlashKeyPrefix = "_flash."
+
+// Adds a flash message for the key, kept in the session until read
+// through Flashes().
+func Flash(sess Session, key string, value any) error {
+	flashes, _ := sess.Get(FlashKeyPrefix + key).([]any)
+	return sess.Set(FlashKeyPrefix+key, append(slices.Clone(flashes), value))
+}
+
+// Returns the flash messages for the key, in the order they were added,
+// and removes them from the session. Returns nil if there's none.
+//
+// If the messages cannot be removed, they're returned again next time.
+func Flashes(sess Session, key string) []any {
+	flashes, _ := sess.Get(FlashKeyPrefix + key).([]any)
+	if flashes == nil {
+		return nil
+	}
+	sess.Delete(FlashKeyPrefix + key)
+	return flashes
+}
+
+// Returns the session from the request context (see Middleware()), or
+// starts it.
+func (m *Manager) session(w http.ResponseWriter, r *http.Request) (Session, error) {
+	if sess := FromContext(r.Context()); sess != nil {
+		return sess, nil
+	}
+	return m.StartSessionE(w, r)
+}
+
+// Adds a flash message for the key into the request session, to be read
+// through Flashes() on a later request (e.g. after a redirect).
+//
+// Returns an error when the session cannot be started or the message
+// cannot be stored.
+func (m *Manager) Flash(w http.ResponseWriter, r *http.Request, key string, value any) error {
+	sess, err := m.session(w, r)
+	if err != nil {
+		return err
+	}
+	return Flash(sess, key, value)
+}
+
+// Returns and removes the flash messages for the key from the request
+// session. Returns nil if there's none or the session cannot be started.
+func (m *Manager) Flashes(w http.ResponseWriter, r *http.Request, key string) []any {
+	sess, err := m.session(w, r)
+	if err != nil {
+		return nil
+	}
+	return Flashes(sess, key)
+}
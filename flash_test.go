@@ -0,0 +1,62 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestFlash(t *testing.T) {
+	sess := newStubSession("abcde")
+
+	err := Flash(sess, "info", "saved")
+	assert.NoError(t, err)
+	err = Flash(sess, "info", "done")
+	assert.NoError(t, err)
+	err = Flash(sess, "error", "failed")
+	assert.NoError(t, err)
+
+	t.Run("returns flashes in order", func(t *testing.T) {
+		got := Flashes(sess, "info")
+
+		assert.Equal(t, got, []any{"saved", "done"})
+	})
+	t.Run("removes read flashes only", func(t *testing.T) {
+		assert.Equal(t, len(Flashes(sess, "info")), 0)
+		assert.Equal(t, Flashes(sess, "error"), []any{"failed"})
+	})
+}
+
+func TestManager_Flash(t *testing.T) {
+	manager := NewManager(&stubProvider{}, "SessionID", 3600)
+
+	req, _ := http.NewRequest(http.MethodPost, dummySite, nil)
+	res := httptest.NewRecorder()
+
+	err := manager.Flash(res, req, "info", "saved")
+	assert.NoError(t, err)
+
+	t.Run("reads flashes on next request", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(res.Result().Cookies()[0])
+
+		got := manager.Flashes(httptest.NewRecorder(), req, "info")
+
+		assert.Equal(t, got, []any{"saved"})
+		assert.Equal(t, len(manager.Flashes(httptest.NewRecorder(), req, "info")), 0)
+	})
+	t.Run("uses the request context session", func(t *testing.T) {
+		var got []any
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			manager.Flash(w, r, "info", "done")
+			got = Flashes(FromContext(r.Context()), "info")
+		}))
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, got, []any{"done"})
+	})
+}
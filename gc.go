@@ -0,0 +1,181 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrGCPanic error = errors.New("session: gc panicked")
+
+// Clock provides the time to the GC scheduler, so it can be faked in
+// tests.
+type Clock interface {
+	Now() time.Time
+	// Returns a ticker that sends the time every d interval.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the ticker created by a Clock, like time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// Sets the clock used by the GC scheduler (see StartGC()). Setting nil
+// restores the real one, which is the default.
+func (m *Manager) SetClock(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clock = c
+}
+
+// GCReport describes a GC run.
+type GCReport struct {
+	// When the run started.
+	Time time.Time
+	// How long the run took.
+	Duration time.Duration
+	// Number of expired sessions removed.
+	Reaped int
+	// Errors occurred while removing the expired sessions, or nil.
+	Err error
+}
+
+// GCHandle controls the GC routine started through StartGC().
+type GCHandle struct {
+	m      *Manager
+	clock  Clock
+	runMu  sync.Mutex // serializes the runs
+	mu     sync.Mutex
+	last   GCReport
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Starts a routine that removes the expired sessions every interval,
+// which is independent of the sessions lifetime. It runs until Stop() is
+// called or ctx is done.
+//
+// Panics if the interval isn't positive.
+func (m *Manager) StartGC(ctx context.Context, interval time.Duration) *GCHandle {
+	if interval <= 0 {
+		panic("non-positive interval")
+	}
+	m.mu.Lock()
+	clock := m.clock
+	m.mu.Unlock()
+	ctx, cancel := context.WithCancel(ctx)
+	h := &GCHandle{
+		m:      m,
+		clock:  clock,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	ticker := clock.NewTicker(interval)
+	go func() {
+		defer close(h.done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				h.RunNow()
+			}
+		}
+	}()
+	return h
+}
+
+// Removes the expired sessions right away, returning the run report. It
+// waits for a run in progress to finish.
+//
+// A panic while removing them (e.g. in the storage) is turned into the
+// report error, so it doesn't crash the routine.
+func (h *GCHandle) RunNow() GCReport {
+	h.runMu.Lock()
+	defer h.runMu.Unlock()
+
+	report := GCReport{Time: h.clock.Now()}
+	report.Reaped, report.Err = h.collect()
+	report.Duration = h.clock.Now().Sub(report.Time)
+
+	log := h.m.logger.get()
+	switch {
+	case report.Err != nil:
+		log.Error("session: gc run failed", "reaped", report.Reaped, "duration", report.Duration, "err", report.Err)
+	case report.Reaped > 0:
+		log.Info("session: gc run", "reaped", report.Reaped, "duration", report.Duration)
+	default:
+		log.Debug("session: gc run", "reaped", report.Reaped, "duration", report.Duration)
+	}
+
+	h.mu.Lock()
+	h.last = report
+	h.mu.Unlock()
+	return report
+}
+
+func (h *GCHandle) collect() (reaped int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrGCPanic, r)
+		}
+	}()
+	return h.m.provider.SessionGC()
+}
+
+// Returns the report of the last run, or the zero value if it didn't
+// run yet.
+func (h *GCHandle) Last() GCReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last
+}
+
+// Stops the routine, waiting for a run in progress to finish. It can be
+// called more than once.
+func (h *GCHandle) Stop() {
+	h.cancel()
+	<-h.done
+}
+
+// Creates a routine to check for expired sessions and remove them, every
+// max age seconds. It cannot be stopped. Does nothing if the max age
+// isn't positive.
+//
+// Deprecated: use StartGC().
+func (m *Manager) GC() {
+	m.mu.RLock()
+	maxAge := m.maxAge
+	m.mu.RUnlock()
+	if maxAge <= 0 {
+		return
+	}
+	h := m.StartGC(context.Background(), time.Duration(maxAge)*time.Second)
+	h.RunNow()
+}
@@ -0,0 +1,189 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	step   time.Duration // added to the time on every Now() call
+	ticker *fakeTicker
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ticker = &fakeTicker{c: make(chan time.Time), d: d}
+	return c.ticker
+}
+
+type fakeTicker struct {
+	c       chan time.Time
+	d       time.Duration
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.stopped = true
+}
+
+type stubGCProvider struct {
+	stubProvider
+	mu       sync.Mutex
+	calls    int
+	reaped   int
+	err      error
+	started  chan struct{}
+	blocking chan struct{}
+}
+
+func (p *stubGCProvider) SessionGC() (int, error) {
+	if p.blocking != nil {
+		p.started <- struct{}{}
+		<-p.blocking
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	return p.reaped, p.err
+}
+
+func TestSecondsAgeChecker(t *testing.T) {
+	checker := SecondsAgeCheckerAdapter(2)
+
+	assert.Equal(t, checker.ShouldReap(time.Now().Add(-1500*time.Millisecond)), false)
+	assert.Equal(t, checker.ShouldReap(time.Now().Add(-2*time.Second)), true)
+}
+
+func TestManager_StartGC(t *testing.T) {
+	newManager := func(provider Provider) (*Manager, *fakeClock) {
+		manager := NewManager(provider, "SessionID", 3600)
+		clock := &fakeClock{now: time.Unix(1000, 0), step: time.Millisecond}
+		manager.SetClock(clock)
+		return manager, clock
+	}
+
+	t.Run("runs on every tick", func(t *testing.T) {
+		provider := &stubGCProvider{reaped: 2}
+		manager, clock := newManager(provider)
+
+		h := manager.StartGC(context.Background(), time.Minute)
+		assert.Equal(t, clock.ticker.d, time.Minute)
+
+		clock.ticker.c <- time.Now()
+		clock.ticker.c <- time.Now()
+		h.Stop()
+
+		assert.Equal(t, provider.calls, 2)
+		assert.Equal(t, h.Last().Reaped, 2)
+		assert.Equal(t, clock.ticker.stopped, true)
+	})
+	t.Run("runs now and reports", func(t *testing.T) {
+		provider := &stubGCProvider{reaped: 3}
+		manager, _ := newManager(provider)
+		h := manager.StartGC(context.Background(), time.Minute)
+		defer h.Stop()
+
+		assert.Equal(t, h.Last(), GCReport{})
+
+		report := h.RunNow()
+
+		assert.Equal(t, report, GCReport{
+			Time:     time.Unix(1000, 0),
+			Duration: time.Millisecond,
+			Reaped:   3,
+		})
+		assert.Equal(t, h.Last(), report)
+	})
+	t.Run("reports the provider error", func(t *testing.T) {
+		manager, _ := newManager(&stubFailingProvider{})
+		h := manager.StartGC(context.Background(), time.Minute)
+		defer h.Stop()
+
+		report := h.RunNow()
+
+		if !errors.Is(report.Err, errFoo) {
+			t.Errorf("expected the provider error, got %v", report.Err)
+		}
+	})
+	t.Run("stops when context is done", func(t *testing.T) {
+		manager, clock := newManager(&stubGCProvider{})
+		ctx, cancel := context.WithCancel(context.Background())
+
+		h := manager.StartGC(ctx, time.Minute)
+		cancel()
+
+		select {
+		case <-h.done:
+		case <-time.After(time.Second):
+			t.Fatal("didn't stop the routine")
+		}
+		assert.Equal(t, clock.ticker.stopped, true)
+		h.Stop() // must not block after the routine stops
+	})
+	t.Run("doesn't lock the manager while running", func(t *testing.T) {
+		provider := &stubGCProvider{started: make(chan struct{}), blocking: make(chan struct{})}
+		manager, _ := newManager(provider)
+		h := manager.StartGC(context.Background(), time.Minute)
+		defer h.Stop()
+
+		go h.RunNow()
+		defer close(provider.blocking)
+		<-provider.started
+
+		done := make(chan struct{})
+		go func() {
+			req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+			manager.StartSession(httptest.NewRecorder(), req)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("didn't start session while the GC runs")
+		}
+	})
+	t.Run("panic on non-positive interval", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if r != "non-positive interval" {
+				t.Errorf("didn't get expected panic, got: %v", r)
+			}
+		}()
+		manager, _ := newManager(&stubGCProvider{})
+		manager.StartGC(context.Background(), 0)
+	})
+}
+
+func TestManager_GC(t *testing.T) {
+	t.Run("does nothing without max age", func(t *testing.T) {
+		provider := &stubGCProvider{}
+		manager := NewManager(provider, "SessionID", 0)
+
+		manager.GC()
+
+		assert.Equal(t, provider.calls, 0)
+	})
+}
@@ -0,0 +1,222 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrInvalidSessionId          error = errors.New("session: invalid sid")
+	ErrUnableToGenerateSessionId error = errors.New("session: unable to generate sid")
+)
+
+// IDGenerator creates the session identifiers.
+type IDGenerator interface {
+	// Returns a new identifier, or an error if it cannot be created.
+	NewID() (string, error)
+	// Checks if the identifier has the format of the ones created by
+	// the generator, returning ErrInvalidSessionId if it doesn't.
+	ValidateID(sid string) error
+}
+
+// IDValidator checks the format of a session identifier, returning
+// an error if it's malformed.
+type IDValidator func(sid string) error
+
+// Checks if the identifier has the format of the ones created by the
+// NewRandomIDGenerator() without prefix, which is the Manager default.
+var DefaultIDValidator IDValidator = NewRandomIDGenerator("").ValidateID
+
+const idPrefixSep = "."
+
+func assertIDPrefix(prefix string) {
+	for _, c := range prefix {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			panic("invalid id prefix")
+		}
+	}
+}
+
+func withIDPrefix(prefix, id string) string {
+	if prefix == "" {
+		return id
+	}
+	return prefix + idPrefixSep + id
+}
+
+func trimIDPrefix(prefix, sid string) (string, bool) {
+	if prefix == "" {
+		return sid, true
+	}
+	return strings.CutPrefix(sid, prefix+idPrefixSep)
+}
+
+// Splits the identifier into the routing prefix given to the generator
+// and the remaining part. The prefix is empty if there's none.
+func SplitIDPrefix(sid string) (prefix, id string) {
+	if prefix, id, ok := strings.Cut(sid, idPrefixSep); ok {
+		return prefix, id
+	}
+	return "", sid
+}
+
+type randomIDGenerator struct {
+	prefix string
+	size   int
+	rand   io.Reader
+}
+
+// Returns a generator of 32 random bytes encoded into url-safe base64,
+// preceded by the prefix (and a dot) when it isn't empty.
+//
+// The prefix, such as a shard or node identifier, can only have
+// letters, digits, '-' and '_'.
+func NewRandomIDGenerator(prefix string) IDGenerator {
+	assertIDPrefix(prefix)
+	return &randomIDGenerator{prefix, 32, rand.Reader}
+}
+
+func (g *randomIDGenerator) NewID() (string, error) {
+	b := make([]byte, g.size)
+	if _, err := io.ReadFull(g.rand, b); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUnableToGenerateSessionId, err)
+	}
+	return withIDPrefix(g.prefix, base64.URLEncoding.EncodeToString(b)), nil
+}
+
+func (g *randomIDGenerator) ValidateID(sid string) error {
+	id, ok := trimIDPrefix(g.prefix, sid)
+	if !ok || len(id) != base64.URLEncoding.EncodedLen(g.size) {
+		return ErrInvalidSessionId
+	}
+	if _, err := base64.URLEncoding.Strict().DecodeString(id); err != nil {
+		return ErrInvalidSessionId
+	}
+	return nil
+}
+
+var crockfordEncoding = base32.NewEncoding("0123456789ABCDEFGHJKMNPQRSTVWXYZ").WithPadding(base32.NoPadding)
+
+type timeIDGenerator struct {
+	prefix string
+	now    func() time.Time
+	rand   io.Reader
+}
+
+// Returns a generator of time sortable identifiers, like ULID. It
+// encodes into Crockford's base32 the milliseconds timestamp (6 bytes)
+// followed by 10 random bytes, preceded by the prefix (and a dot) when
+// it isn't empty.
+//
+// The prefix, such as a shard or node identifier, can only have
+// letters, digits, '-' and '_'.
+func NewTimeIDGenerator(prefix string) IDGenerator {
+	assertIDPrefix(prefix)
+	return &timeIDGenerator{prefix, time.Now, rand.Reader}
+}
+
+func (g *timeIDGenerator) NewID() (string, error) {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(g.now().UnixMilli())<<16)
+	if _, err := io.ReadFull(g.rand, b[6:]); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUnableToGenerateSessionId, err)
+	}
+	return withIDPrefix(g.prefix, crockfordEncoding.EncodeToString(b)), nil
+}
+
+func (g *timeIDGenerator) ValidateID(sid string) error {
+	id, ok := trimIDPrefix(g.prefix, sid)
+	if !ok || len(id) != crockfordEncoding.EncodedLen(16) {
+		return ErrInvalidSessionId
+	}
+	b, err := crockfordEncoding.DecodeString(id)
+	if err != nil || crockfordEncoding.EncodeToString(b) != id {
+		return ErrInvalidSessionId
+	}
+	return nil
+}
+
+type sequentialIDGenerator struct {
+	prefix string
+	n      atomic.Uint64
+}
+
+// Returns a generator of deterministic identifiers, a counter starting
+// from 1 written as 16 hexadecimal digits, preceded by the prefix (and
+// a dot) when it isn't empty. It's meant for tests, never use it to
+// serve real clients.
+//
+// The prefix can only have letters, digits, '-' and '_'.
+func NewSequentialIDGenerator(prefix string) IDGenerator {
+	assertIDPrefix(prefix)
+	return &sequentialIDGenerator{prefix: prefix}
+}
+
+func (g *sequentialIDGenerator) NewID() (string, error) {
+	return withIDPrefix(g.prefix, fmt.Sprintf("%016x", g.n.Add(1))), nil
+}
+
+func (g *sequentialIDGenerator) ValidateID(sid string) error {
+	id, ok := trimIDPrefix(g.prefix, sid)
+	if !ok || len(id) != 16 {
+		return ErrInvalidSessionId
+	}
+	for _, c := range id {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return ErrInvalidSessionId
+		}
+	}
+	return nil
+}
+
+// Sets the generator for the session identifiers, which also becomes
+// the validator for the ones sent by the client (see SetIDValidator()).
+//
+// The default is NewRandomIDGenerator() without prefix.
+func (m *Manager) SetIDGenerator(g IDGenerator) {
+	if g == nil {
+		panic("nil generator")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generator = g
+	m.validateID = g.ValidateID
+}
+
+func (m *Manager) sessionID() (string, error) {
+	return m.generator.NewID()
+}
+
+// Sets the validator for the identifiers sent by the client. A malformed
+// identifier is rejected before reaching the provider, the same way as
+// an unknown one. Setting nil disables the validation.
+//
+// The default is the generator validation (see SetIDGenerator()).
+func (m *Manager) SetIDValidator(v IDValidator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validateID = v
+}
+
+func (m *Manager) validID(sid string) bool {
+	return m.validateID == nil || m.validateID(sid) == nil
+}
+
+// Sets the validator for the session identifiers. Any operation with a
+// malformed identifier returns ErrInvalidSessionId without reaching the
+// storage. Setting nil disables the validation, which is the default.
+func (p *defaultProvider) SetIDValidator(v IDValidator) {
+	p.validateID = v
+}
+
+func (p *defaultProvider) validID(sid string) bool {
+	return p.validateID == nil || p.validateID(sid) == nil
+}
@@ -0,0 +1,166 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestDefaultIDValidator(t *testing.T) {
+	sid, _ := NewRandomIDGenerator("").NewID()
+
+	t.Run("accepts manager session id", func(t *testing.T) {
+		assert.NoError(t, DefaultIDValidator(sid))
+	})
+
+	cases := []struct {
+		desc string
+		sid  string
+	}{
+		{"empty", ""},
+		{"short", "abcde"},
+		{"long", sid + "AAAA"},
+		{"path traversal", "../../../../../../../../../../../etc/passwd"},
+		{"standard base64", strings.Repeat("+", 43) + "="},
+	}
+	for _, c := range cases {
+		t.Run("rejects "+c.desc, func(t *testing.T) {
+			assert.Equal(t, DefaultIDValidator(c.sid), ErrInvalidSessionId)
+		})
+	}
+}
+
+func TestIDGenerators(t *testing.T) {
+	generators := []struct {
+		desc string
+		gen  IDGenerator
+	}{
+		{"random", NewRandomIDGenerator("")},
+		{"random with prefix", NewRandomIDGenerator("node-1")},
+		{"time", NewTimeIDGenerator("")},
+		{"time with prefix", NewTimeIDGenerator("shard_2")},
+		{"sequential", NewSequentialIDGenerator("")},
+		{"sequential with prefix", NewSequentialIDGenerator("test")},
+	}
+
+	for _, g := range generators {
+		t.Run(g.desc+" ids are unique and valid", func(t *testing.T) {
+			seen := map[string]bool{}
+			for range 100 {
+				sid, err := g.gen.NewID()
+
+				assert.NoError(t, err)
+				assert.NoError(t, g.gen.ValidateID(sid), "generated invalid id %q", sid)
+				if seen[sid] {
+					t.Fatalf("generated duplicated id %q", sid)
+				}
+				seen[sid] = true
+			}
+		})
+		t.Run(g.desc+" rejects malformed id", func(t *testing.T) {
+			for _, sid := range []string{"", "abcde", "../etc/passwd", "other.0000000000000001"} {
+				assert.Equal(t, g.gen.ValidateID(sid), ErrInvalidSessionId, "accepted id %q", sid)
+			}
+		})
+	}
+
+	t.Run("embeds the prefix", func(t *testing.T) {
+		sid, _ := NewRandomIDGenerator("node-1").NewID()
+
+		prefix, id := SplitIDPrefix(sid)
+
+		assert.Equal(t, prefix, "node-1")
+		assert.NoError(t, DefaultIDValidator(id))
+	})
+	t.Run("sequential generator is deterministic", func(t *testing.T) {
+		gen := NewSequentialIDGenerator("test")
+
+		first, _ := gen.NewID()
+		second, _ := gen.NewID()
+
+		assert.Equal(t, first, "test.0000000000000001")
+		assert.Equal(t, second, "test.0000000000000002")
+	})
+	t.Run("time generator ids are sortable", func(t *testing.T) {
+		gen := NewTimeIDGenerator("").(*timeIDGenerator)
+		now := time.Now()
+		gen.now = func() time.Time { return now }
+		first, _ := gen.NewID()
+		gen.now = func() time.Time { return now.Add(time.Millisecond) }
+		second, _ := gen.NewID()
+
+		if first >= second {
+			t.Errorf("expected %q to sort before %q", first, second)
+		}
+	})
+	t.Run("returns error on entropy failure", func(t *testing.T) {
+		random := NewRandomIDGenerator("").(*randomIDGenerator)
+		random.rand = strings.NewReader("")
+		timed := NewTimeIDGenerator("").(*timeIDGenerator)
+		timed.rand = strings.NewReader("")
+
+		for _, gen := range []IDGenerator{random, timed} {
+			sid, err := gen.NewID()
+
+			assert.Equal(t, sid, "")
+			if !errors.Is(err, ErrUnableToGenerateSessionId) {
+				t.Errorf("didn't get expected error, got %v", err)
+			}
+		}
+	})
+	t.Run("panic on invalid prefix", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if r != "invalid id prefix" {
+				t.Errorf("didn't get expected panic, got: %v", r)
+			}
+		}()
+		NewRandomIDGenerator("../")
+	})
+}
+
+func TestManager_SetIDGenerator(t *testing.T) {
+	provider := &stubProvider{}
+
+	t.Run("starts session with generated id", func(t *testing.T) {
+		manager := NewManager(provider, "SessionID", 3600)
+		manager.SetIDGenerator(NewSequentialIDGenerator("node"))
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+
+		session := manager.StartSession(res, req)
+
+		assert.Equal(t, session.SessionID(), "node.0000000000000001")
+
+		t.Run("and accepts it back", func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+			req.AddCookie(res.Result().Cookies()[0])
+			res := httptest.NewRecorder()
+
+			got := manager.StartSession(res, req)
+
+			assert.Equal(t, got.SessionID(), session.SessionID())
+		})
+	})
+	t.Run("returns error on generator failure", func(t *testing.T) {
+		manager := NewManager(provider, "SessionID", 3600)
+		random := NewRandomIDGenerator("").(*randomIDGenerator)
+		random.rand = strings.NewReader("")
+		manager.SetIDGenerator(random)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+
+		_, err := manager.StartSessionE(res, req)
+
+		if !errors.Is(err, ErrUnableToStartSession) || !errors.Is(err, ErrUnableToGenerateSessionId) {
+			t.Errorf("didn't get expected error, got %v", err)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package session_test
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -23,7 +24,6 @@ type mockServer struct {
 }
 
 func newServer(manager *session.Manager) *mockServer {
-	manager.GC()
 	return &mockServer{
 		manager,
 		make([]string, 0),
@@ -73,7 +73,7 @@ func (s *mockServer) handleLogIn(w http.ResponseWriter, r *http.Request, sess se
 
 func (s *mockServer) handleGetOnlinePlayers(w http.ResponseWriter, _ *http.Request, sess session.Session) {
 
-	if isLogged, ok := sess.Get("logged").(bool); !ok || !isLogged {
+	if !session.GetOr(sess, "logged", false) {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
@@ -82,8 +82,7 @@ func (s *mockServer) handleGetOnlinePlayers(w http.ResponseWriter, _ *http.Reque
 }
 
 func (s *mockServer) handleStartGame(w http.ResponseWriter, _ *http.Request, sess session.Session) {
-	logged, ok := sess.Get("logged").(bool)
-	if !ok || !logged {
+	if !session.GetOr(sess, "logged", false) {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
@@ -95,8 +94,7 @@ func (s *mockServer) handleStartGame(w http.ResponseWriter, _ *http.Request, ses
 }
 
 func (s *mockServer) handleLeaveGame(w http.ResponseWriter, _ *http.Request, sess session.Session) {
-	logged, ok := sess.Get("logged").(bool)
-	if !ok || !logged {
+	if !session.GetOr(sess, "logged", false) {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
@@ -108,14 +106,13 @@ func (s *mockServer) handleLeaveGame(w http.ResponseWriter, _ *http.Request, ses
 }
 
 func (s *mockServer) handleScore(w http.ResponseWriter, r *http.Request, sess session.Session) {
-	logged, ok := sess.Get("logged").(bool)
-	if !ok || !logged {
+	if !session.GetOr(sess, "logged", false) {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
-	score, ok := sess.Get("score").(int)
-	if !ok {
+	score, ok, err := session.GetAs[int](sess, "score")
+	if !ok || err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
@@ -198,6 +195,9 @@ func performTest(t *testing.T, manager *session.Manager) {
 
 	server := newServer(manager)
 
+	gc := manager.StartGC(context.Background(), time.Second)
+	t.Cleanup(gc.Stop)
+
 	cookieManager := newStubCookieManager()
 
 	parseCookie := func(cookie map[string]string) *http.Cookie {
@@ -0,0 +1,217 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Session that isn't known by the provider until it has data (see
+// SetLazy()). Until then, the values are kept in memory, and the ones
+// that couldn't be copied into the provider session are kept until the
+// next attempt.
+type lazySession struct {
+	mu        sync.Mutex
+	m         *Manager
+	w         http.ResponseWriter
+	id        string
+	ct        time.Time
+	v         map[string]any
+	sess      Session
+	deferred  bool
+	committed bool // the middleware already wrote the response header
+	destroyed bool
+}
+
+var (
+	ErrSessionCommitted error = errors.New("session: response already written, the session cannot be created")
+	ErrSessionDestroyed error = errors.New("session: session was destroyed")
+)
+
+func newLazySession(m *Manager, w http.ResponseWriter, sid string) *lazySession {
+	return &lazySession{
+		m:  m,
+		w:  w,
+		id: sid,
+		ct: m.clock.Now(),
+		v:  map[string]any{},
+	}
+}
+
+func (s *lazySession) Set(key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.destroyed {
+		return ErrSessionDestroyed
+	}
+	if s.sess == nil && s.committed {
+		return ErrSessionCommitted
+	}
+	if s.sess != nil {
+		delete(s.v, key)
+		if err := s.sess.Set(key, value); err != nil {
+			return err
+		}
+	} else {
+		s.v[key] = value
+	}
+	if s.deferred || (s.sess != nil && len(s.v) == 0) {
+		return nil
+	}
+	return s.persist(s.w)
+}
+
+func (s *lazySession) Get(key string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.v[key]; ok {
+		return v
+	}
+	if s.sess != nil {
+		return s.sess.Get(key)
+	}
+	return nil
+}
+
+func (s *lazySession) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.v, key)
+	if s.sess != nil {
+		return s.sess.Delete(key)
+	}
+	return nil
+}
+
+func (s *lazySession) SessionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+func (s *lazySession) CreationTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sess != nil {
+		return s.sess.CreationTime()
+	}
+	return s.ct
+}
+
+func (s *lazySession) AccessTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sess != nil {
+		return s.sess.AccessTime()
+	}
+	return s.ct
+}
+
+// Persists the session if it has values, which happens when the Manager
+// middleware defers it to the end of the request. Then, saves the
+// provider session if it implements Saver.
+func (s *lazySession) Save(w http.ResponseWriter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.destroyed {
+		return nil
+	}
+	if len(s.v) > 0 {
+		if err := s.persist(w); err != nil {
+			return err
+		}
+	}
+	if s.sess == nil {
+		return nil
+	}
+	if saver, ok := s.sess.(Saver); ok {
+		return saver.Save(w)
+	}
+	return nil
+}
+
+// Creates the session through the provider right away, if it wasn't yet.
+func (s *lazySession) create() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sess != nil && len(s.v) == 0 {
+		return nil
+	}
+	return s.persist(s.w)
+}
+
+// Drops the values kept so far, so the session is no longer persisted.
+// Returns whether it was already created through the provider.
+func (s *lazySession) destroy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.destroyed = true
+	clear(s.v)
+	return s.sess != nil
+}
+
+// Replaces the identifier of the session if it wasn't created yet,
+// keeping its values. Returns false if it was.
+func (s *lazySession) rename(sid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sess != nil {
+		return false
+	}
+	s.id = sid
+	return true
+}
+
+// Creates the session through the provider, if it wasn't yet, and sends
+// the identifier to the client. Then, copies the values kept so far into
+// it, keeping the ones that fail to the next attempt.
+func (s *lazySession) persist(w http.ResponseWriter) error {
+	if s.destroyed {
+		return ErrSessionDestroyed
+	}
+	if s.sess == nil {
+		if err := s.init(w); err != nil {
+			return err
+		}
+	}
+	for k, v := range s.v {
+		if err := s.sess.Set(k, v); err != nil {
+			return err
+		}
+		delete(s.v, k)
+	}
+	return nil
+}
+
+func (s *lazySession) init(w http.ResponseWriter) error {
+	sess, err := s.m.provider.SessionInit(s.id)
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return ErrUnableToStartSession
+	}
+	s.m.mu.RLock()
+	s.m.writeID(w, s.id)
+	s.m.mu.RUnlock()
+	s.sess = sess
+	s.m.emitLifecycle(sessionEvent(EventCreated, sess))
+	return nil
+}
+
+// Sets whether new sessions are lazy. A lazy session isn't created by the
+// provider, and the http cookie isn't sent, until a value is set, so
+// requests that don't use the session (e.g. from bots or health checks)
+// don't reach the storage. Disabled by default.
+//
+// Within the Manager middleware, the session is created at the end of the
+// request (right before the response header is written) if values were
+// set. Otherwise, it's created on the first Set(), which must happen
+// before the response header is written. Afterwards, Set() returns
+// ErrSessionCommitted for a session that wasn't created.
+func (m *Manager) SetLazy(lazy bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lazy = lazy
+}
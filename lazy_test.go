@@ -0,0 +1,221 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestManager_SetLazy(t *testing.T) {
+	t.Run("doesn't create session until a value is set", func(t *testing.T) {
+		provider := &stubProvider{}
+		manager := NewManager(provider, "SessionID", 3600)
+		manager.SetLazy(true)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+		sess, err := manager.StartSessionE(res, req)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, sess)
+		assert.Nil(t, sess.Get("foo"))
+		assert.Equal(t, len(provider.Sessions), 0)
+		assert.Equal(t, res.Header().Get("Set-Cookie"), "")
+
+		err = sess.Set("foo", "bar")
+
+		assert.NoError(t, err)
+		assert.NotNil(t, provider.Sessions[sess.SessionID()])
+		assert.Equal(t, provider.Sessions[sess.SessionID()].Get("foo"), any("bar"))
+		assert.Equal(t, len(res.Result().Cookies()), 1)
+	})
+	t.Run("returns provider error on set", func(t *testing.T) {
+		manager := NewManager(&stubFailingProvider{}, "SessionID", 3600)
+		manager.SetLazy(true)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		sess, err := manager.StartSessionE(httptest.NewRecorder(), req)
+		assert.NoError(t, err)
+
+		err = sess.Set("foo", "bar")
+
+		assert.Equal(t, err, errFoo)
+	})
+	t.Run("middleware creates session at the end of the request", func(t *testing.T) {
+		provider := &stubProvider{}
+		manager := NewManager(provider, "SessionID", 3600)
+		manager.SetLazy(true)
+
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sess := FromContext(r.Context())
+			sess.Set("foo", "bar")
+			sess.Set("baz", 1)
+			if len(provider.Sessions) != 0 {
+				t.Error("didn't defer session creation")
+			}
+			assert.Equal(t, sess.Get("foo"), any("bar"))
+		}))
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, len(provider.Sessions), 1)
+		assert.Equal(t, len(res.Result().Cookies()), 1)
+
+		t.Run("and reads it on next request", func(t *testing.T) {
+			var got any
+			handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = FromContext(r.Context()).Get("baz")
+			}))
+
+			req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+			req.AddCookie(res.Result().Cookies()[0])
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, got, any(1))
+		})
+	})
+	t.Run("middleware doesn't create session without values", func(t *testing.T) {
+		provider := &stubProvider{}
+		manager := NewManager(provider, "SessionID", 3600)
+		manager.SetLazy(true)
+
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sess := FromContext(r.Context())
+			sess.Set("foo", "bar")
+			sess.Delete("foo")
+		}))
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, len(provider.Sessions), 0)
+		assert.Equal(t, len(res.Result().Cookies()), 0)
+	})
+	t.Run("middleware doesn't create destroyed session", func(t *testing.T) {
+		provider := &stubProvider{}
+		manager := NewManager(provider, "SessionID", 3600)
+		manager.SetLazy(true)
+
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sess := FromContext(r.Context())
+			sess.Set("foo", "bar")
+
+			err := manager.DestroySessionE(w, r)
+
+			assert.NoError(t, err)
+			assert.Equal(t, sess.Set("baz", 1), ErrSessionDestroyed)
+		}))
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, len(provider.Sessions), 0)
+		cookies := res.Result().Cookies()
+		assert.Equal(t, len(cookies), 1)
+		assert.Equal(t, cookies[0].MaxAge, -1)
+	})
+	t.Run("returns error on set after the response is written", func(t *testing.T) {
+		provider := &stubProvider{}
+		manager := NewManager(provider, "SessionID", 3600)
+		manager.SetLazy(true)
+
+		var err error
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+			err = FromContext(r.Context()).Set("foo", "bar")
+		}))
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, err, ErrSessionCommitted)
+		assert.Equal(t, len(provider.Sessions), 0)
+	})
+}
+
+func TestManager_SetLazy_RegenerateID(t *testing.T) {
+	provider := &stubProvider{}
+	manager := NewManager(provider, "SessionID", 3600)
+	manager.SetLazy(true)
+
+	var before, after Session
+	handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before = FromContext(r.Context())
+		oldSid := before.SessionID()
+		before.Set("cart", "foo")
+
+		sess, err := manager.RegenerateID(w, r)
+
+		assert.NoError(t, err)
+		after = FromContext(r.Context())
+		assert.Equal(t, after, sess)
+		assert.Equal(t, after.Get("cart"), any("foo"))
+		if after.SessionID() == oldSid {
+			t.Error("didn't change the session id")
+		}
+	}))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+	handler.ServeHTTP(res, req)
+
+	assert.Equal(t, len(provider.Sessions), 1)
+	assert.NotNil(t, provider.Sessions[after.SessionID()])
+	assert.Equal(t, provider.Sessions[after.SessionID()].Get("cart"), any("foo"))
+	assert.Equal(t, len(res.Result().Cookies()), 1)
+}
+
+type stubFlakySession struct {
+	*stubSession
+	fails int
+}
+
+func (s *stubFlakySession) Set(key string, value any) error {
+	if s.fails > 0 {
+		s.fails--
+		return errFoo
+	}
+	return s.stubSession.Set(key, value)
+}
+
+// Provider whose sessions fail the first Set.
+type stubFlakyProvider struct {
+	*stubProvider
+}
+
+func (p *stubFlakyProvider) SessionInit(sid string) (Session, error) {
+	if _, err := p.SessionRead(sid); err == nil {
+		return nil, ErrDuplicatedSessionId
+	}
+	sess, _ := p.stubProvider.SessionInit(sid)
+	return &stubFlakySession{sess.(*stubSession), 1}, nil
+}
+
+func TestManager_SetLazy_Retry(t *testing.T) {
+	provider := &stubFlakyProvider{&stubProvider{}}
+	manager := NewManager(provider, "SessionID", 3600)
+	manager.SetLazy(true)
+
+	req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+	sess, err := manager.StartSessionE(httptest.NewRecorder(), req)
+	assert.NoError(t, err)
+
+	err = sess.Set("foo", "bar")
+
+	assert.Equal(t, err, errFoo)
+	assert.Equal(t, sess.Get("foo"), any("bar"))
+
+	err = sess.Set("baz", 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(provider.Sessions), 1)
+	assert.Equal(t, provider.Sessions[sess.SessionID()].Get("foo"), any("bar"))
+	assert.Equal(t, provider.Sessions[sess.SessionID()].Get("baz"), any(1))
+}
@@ -0,0 +1,165 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrLockTimeout error = errors.New("session: timed out waiting for the session lock")
+
+// LockMode defines how a session is locked (see Manager.LockSession()).
+type LockMode int
+
+const (
+	// Allows other shared locks on the session, e.g. for requests that
+	// only read it.
+	SharedLock LockMode = iota
+	// Allows no other lock on the session, e.g. for requests that modify
+	// it.
+	ExclusiveLock
+)
+
+// Lock state of a session identifier, removed when no one holds or
+// waits for it.
+type sidLock struct {
+	readers int
+	writer  bool
+	writers int           // exclusive locks waiting, which block new shared ones
+	refs    int           // holders and waiters
+	release chan struct{} // closed (and replaced) when the lock is released
+}
+
+// Locks session identifiers, with shared and exclusive modes.
+type locker struct {
+	mu    sync.Mutex
+	locks map[string]*sidLock
+}
+
+// Waits until the session identifier is locked in the mode, returning
+// the function that unlocks it. Returns ErrLockTimeout if ctx is done
+// first.
+func (l *locker) lock(ctx context.Context, sid string, mode LockMode) (func(), error) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*sidLock{}
+	}
+	sl, ok := l.locks[sid]
+	if !ok {
+		sl = &sidLock{release: make(chan struct{})}
+		l.locks[sid] = sl
+	}
+	sl.refs++
+	if mode == ExclusiveLock {
+		sl.writers++
+	}
+	for {
+		if mode == ExclusiveLock && !sl.writer && sl.readers == 0 {
+			sl.writers--
+			sl.writer = true
+			break
+		}
+		if mode == SharedLock && !sl.writer && sl.writers == 0 {
+			sl.readers++
+			break
+		}
+		release := sl.release
+		l.mu.Unlock()
+		select {
+		case <-release:
+			l.mu.Lock()
+		case <-ctx.Done():
+			l.mu.Lock()
+			if mode == ExclusiveLock {
+				sl.writers--
+				// shared locks may be waiting for this one
+				sl.broadcast()
+			}
+			l.forget(sid, sl)
+			l.mu.Unlock()
+			return nil, ErrLockTimeout
+		}
+	}
+	l.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if mode == ExclusiveLock {
+				sl.writer = false
+			} else {
+				sl.readers--
+			}
+			sl.broadcast()
+			l.forget(sid, sl)
+		})
+	}, nil
+}
+
+// Wakes up everyone waiting for the lock.
+func (sl *sidLock) broadcast() {
+	close(sl.release)
+	sl.release = make(chan struct{})
+}
+
+func (l *locker) forget(sid string, sl *sidLock) {
+	sl.refs--
+	if sl.refs == 0 {
+		delete(l.locks, sid)
+	}
+}
+
+// Locks the session identifier in the mode, returning the function that
+// unlocks it, so requests for the same session don't read and modify it
+// at the same time. The identifiers are only locked within the Manager,
+// other managers (e.g. in other processes) don't see them.
+//
+// Waits for the lock timeout (see SetLockTimeout()), or forever when it
+// isn't set, returning ErrLockTimeout if the lock isn't acquired.
+func (m *Manager) LockSession(sid string, mode LockMode) (unlock func(), err error) {
+	return m.lockSession(context.Background(), sid, mode)
+}
+
+func (m *Manager) lockSession(ctx context.Context, sid string, mode LockMode) (func(), error) {
+	m.mu.RLock()
+	timeout := m.lockTimeout
+	m.mu.RUnlock()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return m.locks.lock(ctx, sid, mode)
+}
+
+// Sets how long LockSession() waits for the lock, and enables session
+// locking in the Manager middleware: requests with safe methods (GET,
+// HEAD, OPTIONS and TRACE) hold a shared lock and the other ones hold an
+// exclusive lock on their session until the response is saved. Zero
+// disables the locking in the middleware, which is the default.
+func (m *Manager) SetLockTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lockTimeout = timeout
+}
+
+// Locks the session identifier sent by the client, if locking is enabled
+// (see SetLockTimeout()), until the lock timeout or the request is done.
+// Returns a function that does nothing if there's nothing to lock.
+func (m *Manager) lockRequest(r *http.Request) (func(), error) {
+	m.mu.RLock()
+	timeout := m.lockTimeout
+	sid, reason := m.readID(r)
+	m.mu.RUnlock()
+	if timeout <= 0 || sid == "" || reason != "" {
+		return func() {}, nil
+	}
+	mode := ExclusiveLock
+	if safeMethods[r.Method] {
+		mode = SharedLock
+	}
+	return m.lockSession(r.Context(), sid, mode)
+}
@@ -0,0 +1,152 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func lockWithin(l *locker, sid string, mode LockMode, d time.Duration) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return l.lock(ctx, sid, mode)
+}
+
+func TestLocker(t *testing.T) {
+	wait := 20 * time.Millisecond
+
+	t.Run("exclusive lock blocks any other lock", func(t *testing.T) {
+		l := &locker{}
+		unlock, err := lockWithin(l, "abcde", ExclusiveLock, wait)
+		assert.NoError(t, err)
+
+		_, err = lockWithin(l, "abcde", ExclusiveLock, wait)
+		assert.Equal(t, err, ErrLockTimeout)
+		_, err = lockWithin(l, "abcde", SharedLock, wait)
+		assert.Equal(t, err, ErrLockTimeout)
+
+		unlock()
+		unlock, err = lockWithin(l, "abcde", SharedLock, wait)
+		assert.NoError(t, err)
+		unlock()
+	})
+	t.Run("shared locks don't block each other", func(t *testing.T) {
+		l := &locker{}
+		unlock1, err := lockWithin(l, "abcde", SharedLock, wait)
+		assert.NoError(t, err)
+		unlock2, err := lockWithin(l, "abcde", SharedLock, wait)
+		assert.NoError(t, err)
+
+		_, err = lockWithin(l, "abcde", ExclusiveLock, wait)
+		assert.Equal(t, err, ErrLockTimeout)
+
+		unlock1()
+		unlock2()
+		unlock, err := lockWithin(l, "abcde", ExclusiveLock, wait)
+		assert.NoError(t, err)
+		unlock()
+	})
+	t.Run("doesn't block other sessions", func(t *testing.T) {
+		l := &locker{}
+		unlock, _ := lockWithin(l, "abcde", ExclusiveLock, wait)
+		defer unlock()
+
+		other, err := lockWithin(l, "fghij", ExclusiveLock, wait)
+
+		assert.NoError(t, err)
+		other()
+	})
+	t.Run("waiting exclusive lock blocks new shared ones", func(t *testing.T) {
+		l := &locker{}
+		unlock, _ := lockWithin(l, "abcde", SharedLock, wait)
+
+		acquired := make(chan struct{})
+		go func() {
+			unlock, err := lockWithin(l, "abcde", ExclusiveLock, time.Second)
+			if err == nil {
+				close(acquired)
+				unlock()
+			}
+		}()
+		time.Sleep(wait)
+
+		_, err := lockWithin(l, "abcde", SharedLock, wait)
+		assert.Equal(t, err, ErrLockTimeout)
+
+		unlock()
+		select {
+		case <-acquired:
+		case <-time.After(time.Second):
+			t.Error("didn't acquire the exclusive lock")
+		}
+	})
+	t.Run("forgets released locks", func(t *testing.T) {
+		l := &locker{}
+		unlock, _ := lockWithin(l, "abcde", ExclusiveLock, wait)
+		lockWithin(l, "abcde", SharedLock, wait)
+
+		unlock()
+		unlock()
+
+		assert.Equal(t, len(l.locks), 0)
+	})
+}
+
+func TestManager_SetLockTimeout(t *testing.T) {
+	manager := NewManager(&stubProvider{}, "SessionID", 3600)
+	manager.SetLockTimeout(50 * time.Millisecond)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+	sid := manager.StartSession(res, req).SessionID()
+	cookie := res.Result().Cookies()[0]
+
+	t.Run("serializes requests that modify the session", func(t *testing.T) {
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sess := FromContext(r.Context())
+			count, _ := sess.Get("count").(int)
+			time.Sleep(time.Millisecond)
+			sess.Set("count", count+1)
+		}))
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req, _ := http.NewRequest(http.MethodPost, dummySite, nil)
+				req.AddCookie(cookie)
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}()
+		}
+		wg.Wait()
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(cookie)
+		sess := manager.StartSession(httptest.NewRecorder(), req)
+		assert.Equal(t, sess.Get("count"), any(10))
+	})
+	t.Run("responds with unavailable status on timeout", func(t *testing.T) {
+		unlock, err := manager.LockSession(sid, ExclusiveLock)
+		assert.NoError(t, err)
+		defer unlock()
+
+		called := false
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(cookie)
+		res := httptest.NewRecorder()
+
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, res.Code, http.StatusServiceUnavailable)
+		assert.Equal(t, called, false)
+	})
+}
@@ -0,0 +1,95 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+)
+
+// LoggerSetter is implemented by providers and storages that log (e.g. the
+// provider returned by NewProvider(), which also sets the logger to the
+// storage).
+type LoggerSetter interface {
+	SetLogger(l *slog.Logger)
+}
+
+// Returns a digest prefix of the session identifier, to be logged in its
+// place, so the logs cannot be used to hijack the sessions while the same
+// session can still be followed through them.
+func RedactID(sid string) string {
+	if sid == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(sid))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Returns the attribute logging the redacted session identifier.
+func sidAttr(sid string) slog.Attr {
+	return slog.String("sid", RedactID(sid))
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discardLogger = slog.New(discardHandler{})
+
+// Holds the logger, which can be replaced while it's used.
+type logger struct {
+	mu sync.RWMutex
+	l  *slog.Logger
+}
+
+func (lg *logger) set(l *slog.Logger) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	lg.l = l
+}
+
+// Returns the logger, or one that discards everything if it wasn't set.
+func (lg *logger) get() *slog.Logger {
+	lg.mu.RLock()
+	defer lg.mu.RUnlock()
+	if lg.l == nil {
+		return discardLogger
+	}
+	return lg.l
+}
+
+// Sets the logger, and sets it to the provider if it's a LoggerSetter.
+// Setting nil stops logging, which is the default.
+//
+// The Manager logs the rejected session identifiers, the failures to
+// start, destroy, regenerate and save the sessions, and the GC runs. The
+// session identifiers are redacted (see RedactID()).
+func (m *Manager) SetLogger(l *slog.Logger) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logger.set(l)
+	if s, ok := m.provider.(LoggerSetter); ok {
+		s.SetLogger(l)
+	}
+}
+
+// Sets the logger, and sets it to the storage if it's a LoggerSetter.
+// Setting nil stops logging, which is the default.
+//
+// The provider logs the storage failures, which the returned errors
+// don't carry.
+func (p *defaultProvider) SetLogger(l *slog.Logger) {
+	p.logger.set(l)
+	if s, ok := p.storage.(LoggerSetter); ok {
+		s.SetLogger(l)
+	}
+}
+
+// Logs the storage failure of the operation on the session.
+func (p *defaultProvider) logStorageError(op, sid string, err error) {
+	p.logger.get().Error("session: storage failure", "op", op, sidAttr(sid), "err", err)
+}
@@ -0,0 +1,92 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+type panickingGCProvider struct {
+	stubProvider
+}
+
+func (p *panickingGCProvider) SessionGC() (int, error) {
+	panic("boom")
+}
+
+func newBufferLogger() (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), &buf
+}
+
+func TestRedactID(t *testing.T) {
+	sid := "Ab3dE_fGh1"
+	got := RedactID(sid)
+
+	assert.Equal(t, got, RedactID(sid))
+	assert.NotEmpty(t, got)
+	if strings.Contains(got, sid) {
+		t.Errorf("didn't redact the session id, got %q", got)
+	}
+	if got == RedactID("Ab3dE_fGh2") {
+		t.Error("expected different ids to be redacted differently")
+	}
+	assert.Equal(t, RedactID(""), "")
+}
+
+func TestManager_SetLogger(t *testing.T) {
+	t.Run("logs the rejected session id redacted", func(t *testing.T) {
+		logger, buf := newBufferLogger()
+		manager := NewManager(&stubProvider{}, "SessionID", 3600)
+		manager.SetLogger(logger)
+		sid, _ := manager.sessionID()
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(&http.Cookie{Name: "SessionID", Value: url.QueryEscape(sid)})
+		manager.StartSession(httptest.NewRecorder(), req)
+
+		out := buf.String()
+		if !strings.Contains(out, "rejected session id") || !strings.Contains(out, RedactID(sid)) {
+			t.Errorf("didn't log the rejected session id, got %q", out)
+		}
+		if strings.Contains(out, sid) {
+			t.Errorf("logged the session id, got %q", out)
+		}
+	})
+	t.Run("sets the logger to the provider", func(t *testing.T) {
+		logger, buf := newBufferLogger()
+		provider := NewProvider(&stubFailingSessionStorage{}, nil)
+		manager := NewManager(provider, "SessionID", 3600)
+		manager.SetLogger(logger)
+
+		_, err := provider.SessionRead("17af454")
+
+		assert.Error(t, err, ErrUnableToRestoreSession)
+		out := buf.String()
+		if !strings.Contains(out, "storage failure") || !strings.Contains(out, errFoo.Error()) {
+			t.Errorf("didn't log the storage failure, got %q", out)
+		}
+	})
+	t.Run("turns gc panic into error", func(t *testing.T) {
+		logger, buf := newBufferLogger()
+		manager := NewManager(&panickingGCProvider{}, "SessionID", 3600)
+		manager.SetLogger(logger)
+		h := &GCHandle{m: manager, clock: realClock{}}
+
+		report := h.RunNow()
+
+		if !errors.Is(report.Err, ErrGCPanic) || !strings.Contains(report.Err.Error(), "boom") {
+			t.Errorf("didn't get the panic error, got %v", report.Err)
+		}
+		if !strings.Contains(buf.String(), "gc run failed") {
+			t.Errorf("didn't log the gc run, got %q", buf.String())
+		}
+	})
+}
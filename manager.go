@@ -1,11 +1,9 @@
 package session
 
 import (
-	"crypto/rand"
-	"encoding/base64"
-	"io"
+	"errors"
+	"fmt"
 	"net/http"
-	"net/url"
 	"sync"
 	"time"
 )
@@ -19,13 +17,33 @@ type Session interface {
 	Delete(key string) error
 	// Returns session identifier.
 	SessionID() string
+	// Returns when the session was created.
+	CreationTime() time.Time
+	// Returns when the session was last accessed.
+	AccessTime() time.Time
+}
+
+// KeyLister is implemented by sessions that can list their keys (e.g. the
+// ones from the built-in storages).
+type KeyLister interface {
+	// Returns the keys defined in the session, sorted.
+	Keys() []string
 }
 
 type Provider interface {
 	SessionInit(sid string) (Session, error)
 	SessionRead(sid string) (Session, error)
 	SessionDestroy(sid string) error
-	SessionGC(maxAge int64)
+	SessionRegenerate(oldSid, newSid string) (Session, error)
+	// Sets the sessions lifetime since created (maxAge) and since last
+	// accessed (idle, zero disables it).
+	SetExpiration(maxAge, idle int64)
+	SessionGC() (int, error)
+	// Binds the session to the user identifier, or unbinds it when uid is
+	// empty, returning the user sessions evicted by the limit.
+	SessionBindUser(sid, uid string, limit UserLimit) ([]string, error)
+	// Returns the identifiers of the active sessions bound to the user.
+	UserSessions(uid string) ([]string, error)
 }
 
 // Manager allows to work with sessions.
@@ -38,18 +56,37 @@ type Provider interface {
 // - Retrieves, accordingly to the existent http cookie.
 //
 // To destroys a session, it can be forced or after it expires. The
-// expired session is removed through the GC routine, which checks
-// this condition.
+// expired session is removed through the GC routine (see StartGC()),
+// which checks this condition.
 type Manager struct {
-	mu         sync.Mutex
-	provider   Provider
-	cookieName string
-	maxAge     int64
+	mu          sync.RWMutex
+	provider    Provider
+	cookieName  string
+	cookie      *cookieTransport
+	transport   Transport
+	maxAge      int64
+	idle        int64
+	generator   IDGenerator
+	validateID  IDValidator
+	signer      *signer
+	events      events
+	metrics     metrics
+	logger      logger
+	subscribed  bool // provider emits the lifecycle events
+	clock       Clock
+	lazy        bool
+	locks       locker
+	lockTimeout time.Duration
+	userLimit   UserLimit
 }
 
 // Returns a new Manager (address for pointer reference).
 //
-// The provider cannot be nil and cookie name cannot be empty.
+// The provider cannot be nil and cookie name cannot be empty. The session
+// identifier is carried by a http cookie with this name, which attributes
+// are the DefaultCookieOptions(), secure when the name has "__Secure-" or
+// "__Host-" prefix. To change them, use SetCookieOptions(), or use
+// SetTransport() to carry the identifier in another way.
 func NewManager(provider Provider, cookieName string, maxAge int64) *Manager {
 	if provider == nil {
 		panic("nil provider")
@@ -57,19 +94,25 @@ func NewManager(provider Provider, cookieName string, maxAge int64) *Manager {
 	if cookieName == "" {
 		panic("empty cookie name")
 	}
-	return &Manager{
+	provider.SetExpiration(maxAge, 0)
+	generator := NewRandomIDGenerator("")
+	cookie := NewCookieTransport(cookieName)
+	m := &Manager{
 		provider:   provider,
 		cookieName: cookieName,
+		cookie:     cookie,
+		transport:  cookie,
 		maxAge:     maxAge,
+		generator:  generator,
+		validateID: generator.ValidateID,
+		clock:      realClock{},
 	}
-}
-
-func (m *Manager) sessionID() string {
-	b := make([]byte, 32)
-	if _, err := io.ReadFull(rand.Reader, b); err != nil {
-		return ""
+	m.events.onAll(m.report)
+	if src, ok := provider.(EventSource); ok {
+		src.Subscribe(m.events.emit)
+		m.subscribed = true
 	}
-	return base64.URLEncoding.EncodeToString(b)
+	return m
 }
 
 func (m *Manager) assertProviderAndCookieName() {
@@ -81,49 +124,271 @@ func (m *Manager) assertProviderAndCookieName() {
 	}
 }
 
-// Creates or retrieve the session based on the http cookie.
-func (m *Manager) StartSession(w http.ResponseWriter, r *http.Request) (session Session) {
+var ErrUnableToStartSession error = errors.New("session: unable to start the session")
+
+// Creates or retrieve the session based on the identifier sent by the
+// client (the http cookie, unless SetTransport() was used).
+//
+// Panics when the session cannot be started, use StartSessionE() to
+// handle the failure.
+func (m *Manager) StartSession(w http.ResponseWriter, r *http.Request) Session {
+	session, err := m.StartSessionE(w, r)
+	if err != nil {
+		panic("unable to start the session")
+	}
+	return session
+}
+
+// Creates or retrieve the session based on the http cookie. When the
+// session identifier cannot be trusted (see SetSigningKeys()), is
+// malformed (see SetIDValidator()) or the provider doesn't know it
+// (ErrUnknownSessionId), it's rejected and a new session is created.
+//
+// Returns an error, wrapping ErrUnableToStartSession and the provider
+// error, when the session cannot be started.
+func (m *Manager) StartSessionE(w http.ResponseWriter, r *http.Request) (session Session, err error) {
 	m.assertProviderAndCookieName()
+	// The lock isn't held while the provider is called, so the hooks can
+	// use the Manager.
+	m.mu.RLock()
+	sid, reason := m.readID(r)
+	m.mu.RUnlock()
+	switch {
+	case sid == "":
+		session, err = m.initSession(w)
+	case reason != "":
+		m.reject(sid, reason)
+		session, err = m.initSession(w)
+	default:
+		session, err = m.provider.SessionRead(sid)
+		if errors.Is(err, ErrUnknownSessionId) {
+			m.reject(sid, "unknown sid")
+			session, err = m.initSession(w)
+		} else if err == nil && session != nil {
+			m.emitLifecycle(sessionEvent(EventRead, session))
+			m.mu.RLock()
+			if m.idle > 0 {
+				m.writeID(w, sid)
+			}
+			m.mu.RUnlock()
+		}
+	}
+	if err != nil {
+		m.logger.get().Error("session: unable to start the session", "err", err)
+		return nil, fmt.Errorf("%w: %w", ErrUnableToStartSession, err)
+	}
+	if session == nil {
+		return nil, ErrUnableToStartSession
+	}
+	return session, nil
+}
+
+// Rejects the session identifier sent by the client.
+func (m *Manager) reject(sid, reason string) {
+	m.logger.get().Warn("session: rejected session id", sidAttr(sid), "reason", reason)
+	m.events.emit(Event{Type: EventRejected, SID: sid, Reason: reason})
+}
+
+// Returns the session identifier sent by the client, or empty if there
+// is none. When the identifier cannot be trusted, also returns the
+// reason to reject it.
+func (m *Manager) readID(r *http.Request) (sid string, reason string) {
+	value := m.transport.ReadID(r)
+	if value == "" {
+		return "", ""
+	}
+	sid, ok := m.verifyID(value)
+	if !ok {
+		return value, "invalid signature"
+	}
+	if !m.validID(sid) {
+		return sid, "invalid sid"
+	}
+	return sid, ""
+}
+
+// Sends the session identifier to the client. The Manager lock must be
+// held, as in readID() and clearID().
+func (m *Manager) writeID(w http.ResponseWriter, sid string) {
+	m.transport.WriteID(w, m.signID(sid), int(m.cookieMaxAge()))
+}
+
+// Returns the lifetime of the identifier in the client, which is the
+// idle timeout when it's shorter than the max age.
+func (m *Manager) cookieMaxAge() int64 {
+	if m.idle > 0 && m.idle < m.maxAge {
+		return m.idle
+	}
+	return m.maxAge
+}
+
+// Sets the time a session can stay unused before it expires, in the same
+// unit as the max age given to NewManager(), which still caps the session
+// lifetime. Every time the session is started, it's extended and the
+// http cookie is reissued. Zero disables it, which is the default.
+func (m *Manager) SetIdleTimeout(idle int64) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	cookie, err := r.Cookie(m.cookieName)
-	if err != nil || cookie.Value == "" {
-		sid := m.sessionID()
-		session, err = m.provider.SessionInit(sid)
-		cookie := http.Cookie{Name: m.cookieName, Value: url.QueryEscape(sid), Path: "/", HttpOnly: true, MaxAge: int(m.maxAge)}
-		http.SetCookie(w, &cookie)
-	} else {
-		sid, _ := url.QueryUnescape(cookie.Value)
-		session, err = m.provider.SessionRead(sid)
+	m.idle = idle
+	m.provider.SetExpiration(m.maxAge, idle)
+}
+
+func (m *Manager) clearID(w http.ResponseWriter) {
+	m.transport.ClearID(w)
+}
+
+func (m *Manager) initSession(w http.ResponseWriter) (Session, error) {
+	m.mu.RLock()
+	sid, err := m.sessionID()
+	lazy := m.lazy
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
 	}
-	if err != nil || session == nil {
-		panic("unable to start the session")
+	if lazy {
+		return newLazySession(m, w, sid), nil
 	}
-	return
+	session, err := m.provider.SessionInit(sid)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	m.writeID(w, sid)
+	m.mu.RUnlock()
+	if session != nil {
+		m.emitLifecycle(sessionEvent(EventCreated, session))
+	}
+	return session, nil
 }
 
 // Destroys the session, finally cleaning up the http cookie.
+//
+// The provider failure is ignored, use DestroySessionE() to handle it.
 func (m *Manager) DestroySession(w http.ResponseWriter, r *http.Request) {
+	m.DestroySessionE(w, r)
+}
+
+// Destroys the session, finally cleaning up the http cookie.
+//
+// Returns the provider error when the session cannot be destroyed (e.g.
+// ErrUnableToDestroySession). In this case, the http cookie is kept. A
+// session identifier that cannot be trusted doesn't reach the provider,
+// only the http cookie is cleaned up.
+//
+// The session is the one in the request context, if any (see
+// Middleware()), or the one of the http cookie otherwise. A lazy session
+// (see SetLazy()) that wasn't created yet is just dropped.
+func (m *Manager) DestroySessionE(w http.ResponseWriter, r *http.Request) error {
 	m.assertProviderAndCookieName()
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	cookie, err := r.Cookie(m.cookieName)
-	if err != nil || cookie.Value == "" {
-		return
+	var sid, reason string
+	if sess := FromContext(r.Context()); sess != nil {
+		if l, ok := sess.(*lazySession); ok && !l.destroy() {
+			m.mu.RLock()
+			m.clearID(w)
+			m.mu.RUnlock()
+			return nil
+		}
+		sid = sess.SessionID()
+	}
+	if sid == "" {
+		m.mu.RLock()
+		sid, reason = m.readID(r)
+		m.mu.RUnlock()
+	}
+	if sid == "" {
+		return nil
+	}
+	if reason == "" {
+		if err := m.provider.SessionDestroy(sid); err != nil {
+			m.logger.get().Error("session: unable to destroy the session", sidAttr(sid), "err", err)
+			return err
+		}
+		m.emitLifecycle(Event{Type: EventDestroyed, SID: sid})
+	}
+	m.mu.RLock()
+	m.clearID(w)
+	m.mu.RUnlock()
+	return nil
+}
+
+// Moves the current session data under a new identifier, removing the
+// old one, and reissues the http cookie. It should be called whenever
+// the privilege level changes (e.g. at login) to prevent session
+// fixation.
+//
+// The current session is the one in the request context, if any (see
+// Middleware()), which is replaced by the returned one, or the one of the
+// http cookie otherwise. If there is no current session, a new one is
+// started. A lazy session (see SetLazy()) that wasn't created yet keeps
+// its values under the new identifier.
+//
+// Returns an error, wrapping ErrUnableToStartSession and the provider
+// error, when the session cannot be regenerated.
+func (m *Manager) RegenerateID(w http.ResponseWriter, r *http.Request) (Session, error) {
+	m.assertProviderAndCookieName()
+	// The context session may not be the cookie one (e.g. when the
+	// cookie was rejected, or the session was created by this request).
+	var oldSid string
+	if sess := FromContext(r.Context()); sess != nil {
+		if l, ok := sess.(*lazySession); ok {
+			renamed, err := m.renameLazy(l)
+			if err != nil {
+				return nil, err
+			}
+			if renamed {
+				return l, nil
+			}
+		}
+		oldSid = sess.SessionID()
+	}
+	m.mu.RLock()
+	if oldSid == "" {
+		var reason string
+		oldSid, reason = m.readID(r)
+		if reason != "" {
+			oldSid = ""
+		}
+	}
+	sid, err := m.sessionID()
+	m.mu.RUnlock()
+	var session Session
+	if err == nil {
+		if oldSid == "" {
+			session, err = m.provider.SessionInit(sid)
+		} else {
+			session, err = m.provider.SessionRegenerate(oldSid, sid)
+		}
+	}
+	if err != nil {
+		m.logger.get().Error("session: unable to regenerate the session", sidAttr(oldSid), "err", err)
+		return nil, fmt.Errorf("%w: %w", ErrUnableToStartSession, err)
+	}
+	if session == nil {
+		return nil, ErrUnableToStartSession
+	}
+	m.mu.RLock()
+	m.writeID(w, sid)
+	m.mu.RUnlock()
+	replaceInContext(r.Context(), session)
+	if oldSid == "" {
+		m.emitLifecycle(sessionEvent(EventCreated, session))
 	} else {
-		sid, _ := url.QueryUnescape(cookie.Value)
-		m.provider.SessionDestroy(sid)
-		cookie := http.Cookie{Name: m.cookieName, Value: url.QueryEscape(sid), Path: "/", HttpOnly: true, Expires: time.Now(), MaxAge: -1}
-		http.SetCookie(w, &cookie)
+		ev := sessionEvent(EventRegenerated, session)
+		ev.OldSID = oldSid
+		m.emitLifecycle(ev)
 	}
+	return session, nil
 }
 
-// Creates a routine to check for expired sessions and remove them.
-func (m *Manager) GC() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.provider.SessionGC(m.maxAge)
-	time.AfterFunc(time.Duration(m.maxAge), func() {
-		m.GC()
-	})
+// Gives a new identifier to the lazy session, if it wasn't created yet.
+// Returns whether it was renamed.
+func (m *Manager) renameLazy(l *lazySession) (bool, error) {
+	m.mu.RLock()
+	sid, err := m.sessionID()
+	m.mu.RUnlock()
+	if err != nil {
+		m.logger.get().Error("session: unable to regenerate the session", "err", err)
+		return false, fmt.Errorf("%w: %w", ErrUnableToStartSession, err)
+	}
+	return l.rename(sid), nil
 }
@@ -1,6 +1,8 @@
 package session
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -71,6 +73,66 @@ func TestManager(t *testing.T) {
 		assert.Equal(t, cookie.Value, url.QueryEscape(session.SessionID()))
 	})
 
+	unknownSid, _ := manager.sessionID()
+	rejectCases := []struct {
+		desc string
+		sid  string
+	}{
+		{"unknown", unknownSid},
+		{"malformed", "../../etc/passwd"},
+	}
+	for _, c := range rejectCases {
+		t.Run(fmt.Sprintf("rejects %s session id", c.desc), func(t *testing.T) {
+			var events []Event
+			manager := NewManager(provider, cookieName, 3600)
+			manager.OnReject(func(e Event) {
+				events = append(events, e)
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+			req.AddCookie(&http.Cookie{Name: cookieName, Value: url.QueryEscape(c.sid)})
+			res := httptest.NewRecorder()
+
+			session := manager.StartSession(res, req)
+			assert.NotNil(t, session)
+
+			if session.SessionID() == c.sid {
+				t.Fatal("didn't discard client session id")
+			}
+			newCookie := parseCookie(getCookieFromResponse(res))
+			assert.Equal(t, newCookie.Value, url.QueryEscape(session.SessionID()))
+
+			if len(events) != 1 || events[0].Type != EventRejected || events[0].SID != c.sid {
+				t.Errorf("didn't raise rejected event, got %+v", events)
+			}
+		})
+	}
+
+	t.Run("regenerates the session id", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(cookie)
+		res := httptest.NewRecorder()
+
+		oldSid, _ := url.QueryUnescape(cookie.Value)
+		provider.Sessions[oldSid].(*stubSession).V = map[string]any{"foo": "bar"}
+
+		session, err := manager.RegenerateID(res, req)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, session)
+
+		if session.SessionID() == oldSid {
+			t.Fatal("didn't change the session id")
+		}
+		if _, ok := provider.Sessions[oldSid]; ok {
+			t.Error("didn't remove the old session")
+		}
+		assert.Equal(t, session.Get("foo"), any("bar"))
+
+		cookie = parseCookie(getCookieFromResponse(res))
+		assert.Equal(t, cookie.Value, url.QueryEscape(session.SessionID()))
+	})
+
 	t.Run("destroy the session", func(t *testing.T) {
 
 		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
@@ -110,6 +172,42 @@ func TestManager(t *testing.T) {
 
 		manager.StartSession(res, req)
 	})
+
+	t.Run("returns error when fail to start session", func(t *testing.T) {
+		manager := NewManager(&stubFailingProvider{}, cookieName, 3600)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+
+		session, err := manager.StartSessionE(res, req)
+
+		assert.Nil(t, session)
+		assert.Error(t, err)
+		if !errors.Is(err, ErrUnableToStartSession) || !errors.Is(err, errFoo) {
+			t.Errorf("didn't wrap expected errors, got %v", err)
+		}
+		if len(res.Header()["Set-Cookie"]) != 0 {
+			t.Error("didn't expect cookie to be set")
+		}
+	})
+
+	t.Run("returns error when fail to destroy session", func(t *testing.T) {
+		manager := NewManager(&stubFailingProvider{}, cookieName, 3600)
+
+		sid, _ := manager.sessionID()
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(&http.Cookie{Name: cookieName, Value: url.QueryEscape(sid)})
+		res := httptest.NewRecorder()
+
+		err := manager.DestroySessionE(res, req)
+
+		if !errors.Is(err, errFoo) {
+			t.Errorf("didn't get expected error, got %v", err)
+		}
+		if len(res.Header()["Set-Cookie"]) != 0 {
+			t.Error("didn't expect cookie to be cleaned up")
+		}
+	})
 }
 
 func getCookieFromResponse(res *httptest.ResponseRecorder) (cookie map[string]string) {
@@ -132,3 +230,50 @@ func getCookieFromResponse(res *httptest.ResponseRecorder) (cookie map[string]st
 
 	return
 }
+
+func TestManager_SetIdleTimeout(t *testing.T) {
+	provider := &stubProvider{}
+	manager := NewManager(provider, "SessionID", 3600)
+
+	assert.Equal(t, provider.MaxAge, int64(3600))
+	assert.Equal(t, provider.Idle, int64(0))
+
+	manager.SetIdleTimeout(600)
+
+	assert.Equal(t, provider.Idle, int64(600))
+
+	req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+	res := httptest.NewRecorder()
+
+	session := manager.StartSession(res, req)
+	cookie := res.Result().Cookies()[0]
+
+	assert.Equal(t, cookie.MaxAge, 600)
+
+	t.Run("reissues the cookie when the session is restored", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(cookie)
+		res := httptest.NewRecorder()
+
+		got := manager.StartSession(res, req)
+
+		assert.Equal(t, got.SessionID(), session.SessionID())
+		cookies := res.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("expected the cookie to be reissued, got %v", cookies)
+		}
+		assert.Equal(t, cookies[0].Value, cookie.Value)
+		assert.Equal(t, cookies[0].MaxAge, 600)
+	})
+	t.Run("caps the cookie max age", func(t *testing.T) {
+		manager := NewManager(&stubProvider{}, "SessionID", 300)
+		manager.SetIdleTimeout(600)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+
+		manager.StartSession(res, req)
+
+		assert.Equal(t, res.Result().Cookies()[0].MaxAge, 300)
+	})
+}
@@ -2,6 +2,8 @@ package memory
 
 import (
 	"container/list"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
@@ -9,16 +11,21 @@ import (
 )
 
 type session struct {
+	mu sync.RWMutex
 	id string         // session id (sid)
 	v  map[string]any // mapped values
 	ct time.Time      // creationtime
+	at time.Time      // last access time
+	u  string         // bound user id, guarded by the storage
 }
 
 func newSession(sid string) *session {
+	now := time.Now()
 	return &session{
 		id: sid,
 		v:  map[string]any{},
-		ct: time.Now(),
+		ct: now,
+		at: now,
 	}
 }
 
@@ -26,40 +33,84 @@ func (s *session) SessionID() string {
 	return s.id
 }
 
+func (s *session) CreationTime() time.Time {
+	return s.ct
+}
+
+func (s *session) AccessTime() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.at
+}
+
 func (s *session) Get(key string) any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.v[key]
 }
 
 func (s *session) Set(key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.v[key] = value
+	s.at = time.Now()
 	return nil
 }
 
 func (s *session) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	delete(s.v, key)
+	s.at = time.Now()
 	return nil
 }
 
+// Returns the keys defined in the session, sorted.
+func (s *session) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Sorted(maps.Keys(s.v))
+}
+
+func (s *session) info() sessionpkg.SessionInfo {
+	return sessionpkg.SessionInfo{
+		ID:       s.id,
+		Created:  s.ct,
+		Accessed: s.AccessTime(),
+		UID:      s.u,
+	}
+}
+
+func (s *session) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.at = time.Now()
+}
+
 type storage struct {
 	mu       sync.Mutex
 	sessions map[string]*list.Element
 	list     *list.List
+	users    map[string]map[string]struct{} // session ids by user id
+	metrics  sessionpkg.Metrics
 }
 
 func newStorage() *storage {
 	return &storage{
 		sessions: map[string]*list.Element{},
 		list:     list.New(),
+		users:    map[string]map[string]struct{}{},
 	}
 }
 
 // Returns a session or an error if cannot creates a session into the storage.
-func (s *storage) CreateSession(sid string) (sessionpkg.Session, error) {
+func (s *storage) CreateSession(sid string) (_ sessionpkg.Session, err error) {
 	if sid == "" {
 		panic("empty sid")
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observe("CreateSession", time.Now(), &err)
 	sess := newSession(sid)
 	if err := s.insertSession(sess); err != nil {
 		return nil, err
@@ -77,6 +128,7 @@ func (s *storage) insertSession(sess *session) error {
 func (s *storage) GetSession(sid string) (sessionpkg.Session, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observe("GetSession", time.Now(), nil)
 	if elem, ok := s.sessions[sid]; ok {
 		sess := elem.Value.(*session)
 		return sess, nil
@@ -88,6 +140,7 @@ func (s *storage) GetSession(sid string) (sessionpkg.Session, error) {
 func (s *storage) ContainsSession(sid string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observe("ContainsSession", time.Now(), nil)
 	_, ok := s.sessions[sid]
 	return ok, nil
 }
@@ -96,27 +149,195 @@ func (s *storage) ContainsSession(sid string) (bool, error) {
 func (s *storage) ReapSession(sid string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observe("ReapSession", time.Now(), nil)
 	if elem, ok := s.sessions[sid]; ok {
+		s.unbind(elem.Value.(*session))
 		delete(s.sessions, sid)
 		s.list.Remove(elem)
 	}
 	return nil
 }
 
-// Scans the storage removing expired sessions.
-func (s *storage) Deadline(checker sessionpkg.AgeChecker) {
+// Moves the session values to a new session identifier, keeping it's
+// creation and access times. Returns nil if there's no session for the old one, or
+// an error if the new one is already in use.
+func (s *storage) RegenerateSession(oldSid, newSid string) (_ sessionpkg.Session, err error) {
+	if newSid == "" {
+		panic("empty sid")
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.observe("RegenerateSession", time.Now(), &err)
+	elem, ok := s.sessions[oldSid]
+	if !ok {
+		return nil, nil
+	}
+	if _, ok := s.sessions[newSid]; ok {
+		return nil, sessionpkg.ErrDuplicatedSessionId
+	}
+	old := elem.Value.(*session)
+	old.mu.RLock()
+	sess := &session{
+		id: newSid,
+		v:  maps.Clone(old.v),
+		ct: old.ct,
+		at: old.at,
+	}
+	old.mu.RUnlock()
+	uid := old.u
+	s.unbind(old)
+	s.bind(sess, uid)
+	elem.Value = sess
+	delete(s.sessions, oldSid)
+	s.sessions[newSid] = elem
+	return sess, nil
+}
 
-	for elem := s.list.Back(); elem != nil; elem = s.list.Back() {
+// Updates the session access time.
+func (s *storage) TouchSession(sid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.observe("TouchSession", time.Now(), nil)
+	if elem, ok := s.sessions[sid]; ok {
+		elem.Value.(*session).touch()
+	}
+	return nil
+}
+
+// Scans the storage removing expired sessions, returning them.
+//
+// Without idle checker, the scan stops at the first session that isn't
+// expired, since they're sorted by creation time.
+func (s *storage) Deadline(absolute, idle sessionpkg.AgeChecker) ([]sessionpkg.ExpiredSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.observe("Deadline", time.Now(), nil)
+
+	var reaped []sessionpkg.ExpiredSession
+	for elem := s.list.Back(); elem != nil; {
 		sess := elem.Value.(*session)
-		if checker.ShouldReap(sess.ct) {
+		prev := elem.Prev()
+		if lifetime := sessionpkg.ExpiredLifetime(absolute, idle, sess.ct, sess.AccessTime()); lifetime != "" {
+			reaped = append(reaped, sessionpkg.ExpiredSession{SessionInfo: sess.info(), Lifetime: lifetime})
+			s.unbind(sess)
 			delete(s.sessions, sess.id)
 			s.list.Remove(elem)
-			continue
+		} else if idle == nil {
+			break
 		}
-		break
+		elem = prev
+	}
+	return reaped, nil
+}
+
+// Binds the session to the user identifier, or unbinds it when uid is
+// empty. When the user would hold more sessions than the limit, the
+// oldest ones are removed and returned, or an error is returned if the
+// limit doesn't evict them. Returns an error if the session doesn't
+// exist.
+func (s *storage) BindUser(sid, uid string, limit sessionpkg.UserLimit) (_ []string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.observe("BindUser", time.Now(), &err)
+	elem, ok := s.sessions[sid]
+	if !ok {
+		return nil, sessionpkg.ErrUnknownSessionId
+	}
+	sess := elem.Value.(*session)
+	var evicted []string
+	if limit.Max > 0 && uid != "" && sess.u != uid {
+		var held []*session
+		for id := range s.users[uid] {
+			held = append(held, s.sessions[id].Value.(*session))
+		}
+		if n := len(held) - limit.Max + 1; n > 0 {
+			if !limit.EvictOldest {
+				return nil, &sessionpkg.SessionLimitError{UID: uid, Max: limit.Max}
+			}
+			slices.SortFunc(held, func(a, b *session) int {
+				return a.ct.Compare(b.ct)
+			})
+			for _, old := range held[:n] {
+				s.unbind(old)
+				s.list.Remove(s.sessions[old.id])
+				delete(s.sessions, old.id)
+				evicted = append(evicted, old.id)
+			}
+		}
+	}
+	s.unbind(sess)
+	s.bind(sess, uid)
+	return evicted, nil
+}
+
+// Returns the identifiers of the sessions bound to the user, sorted.
+func (s *storage) UserSessions(uid string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.observe("UserSessions", time.Now(), nil)
+	return slices.Sorted(maps.Keys(s.users[uid])), nil
+}
+
+// Returns the sessions kept by the storage, sorted by creation time.
+func (s *storage) ListSessions() ([]sessionpkg.SessionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.observe("ListSessions", time.Now(), nil)
+	infos := make([]sessionpkg.SessionInfo, 0, len(s.sessions))
+	for elem := s.list.Back(); elem != nil; elem = elem.Prev() {
+		infos = append(infos, elem.Value.(*session).info())
+	}
+	return infos, nil
+}
+
+// Sets the Metrics that the operations and the number of sessions are
+// reported to. Setting nil stops reporting.
+func (s *storage) SetMetrics(m sessionpkg.Metrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = m
+	if m != nil {
+		m.LiveSessions(len(s.sessions))
+	}
+}
+
+// Reports the operation, started at the given time, and the number of
+// sessions. Must be called holding the lock.
+func (s *storage) observe(op string, start time.Time, err *error) {
+	if s.metrics == nil {
+		return
+	}
+	var e error
+	if err != nil {
+		e = *err
+	}
+	s.metrics.StorageOperation(op, time.Since(start), e)
+	s.metrics.LiveSessions(len(s.sessions))
+}
+
+func (s *storage) bind(sess *session, uid string) {
+	sess.u = uid
+	if uid == "" {
+		return
+	}
+	if s.users == nil {
+		s.users = map[string]map[string]struct{}{}
+	}
+	if s.users[uid] == nil {
+		s.users[uid] = map[string]struct{}{}
+	}
+	s.users[uid][sess.id] = struct{}{}
+}
+
+func (s *storage) unbind(sess *session) {
+	if sess.u == "" {
+		return
+	}
+	delete(s.users[sess.u], sess.id)
+	if len(s.users[sess.u]) == 0 {
+		delete(s.users, sess.u)
 	}
+	sess.u = ""
 }
 
 var _storage = newStorage()
@@ -1,18 +1,23 @@
 package memory
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
+	sessionpkg "github.com/xandalm/go-session"
 	"github.com/xandalm/go-session/testing/assert"
 )
 
 func TestSession_SessionID(t *testing.T) {
 	sess := &session{
-		"abcde",
-		map[string]any{},
-		time.Now(),
+		id: "abcde",
+		v:  map[string]any{},
+		ct: time.Now(),
+		at: time.Now(),
 	}
 
 	got := sess.SessionID()
@@ -25,9 +30,10 @@ func TestSession_SessionID(t *testing.T) {
 
 func TestSession_Get(t *testing.T) {
 	sess := &session{
-		"abcde",
-		map[string]any{"foo": "bar"},
-		time.Now(),
+		id: "abcde",
+		v:  map[string]any{"foo": "bar"},
+		ct: time.Now(),
+		at: time.Now(),
 	}
 
 	got := sess.Get("foo")
@@ -40,9 +46,10 @@ func TestSession_Get(t *testing.T) {
 
 func TestSession_Set(t *testing.T) {
 	sess := &session{
-		"abcde",
-		map[string]any{},
-		time.Now(),
+		id: "abcde",
+		v:  map[string]any{},
+		ct: time.Now(),
+		at: time.Now().Add(-time.Second),
 	}
 	key := "foo"
 	value := "bar"
@@ -58,13 +65,17 @@ func TestSession_Set(t *testing.T) {
 	if got != value {
 		t.Errorf("got value %q, but want %q", got, value)
 	}
+	if time.Since(sess.at) > 100*time.Millisecond {
+		t.Errorf("didn't update access time, got %v", sess.at)
+	}
 }
 
 func TestSession_Delete(t *testing.T) {
 	sess := &session{
-		"abcde",
-		map[string]any{"foo": "bar"},
-		time.Now(),
+		id: "abcde",
+		v:  map[string]any{"foo": "bar"},
+		ct: time.Now(),
+		at: time.Now().Add(-time.Second),
 	}
 
 	err := sess.Delete("foo")
@@ -74,6 +85,9 @@ func TestSession_Delete(t *testing.T) {
 	if _, ok := sess.v["foo"]; ok {
 		t.Error("didn't delete value")
 	}
+	if time.Since(sess.at) > 100*time.Millisecond {
+		t.Errorf("didn't update access time, got %v", sess.at)
+	}
 }
 
 func TestStorage_CreateSession(t *testing.T) {
@@ -139,6 +153,52 @@ func TestStorage_ReapSession(t *testing.T) {
 	}
 }
 
+func TestStorage_RegenerateSession(t *testing.T) {
+	t.Run("moves session to new id", func(t *testing.T) {
+		sess := newSession("abcde")
+		sess.v["foo"] = "bar"
+		storage := newStorage()
+
+		err := storage.insertSession(sess)
+		assert.NoError(t, err)
+
+		got, err := storage.RegenerateSession("abcde", "fghij")
+
+		assert.NoError(t, err)
+		assert.NotNil(t, got)
+		assert.Equal(t, got.SessionID(), "fghij")
+		assert.Equal(t, got.Get("foo"), any("bar"))
+		assert.Equal(t, got.(*session).ct, sess.ct)
+
+		if _, ok := storage.sessions["abcde"]; ok {
+			t.Error("didn't remove old session")
+		}
+		if _, ok := storage.sessions["fghij"]; !ok {
+			t.Error("didn't store new session")
+		}
+		if storage.list.Len() != 1 {
+			t.Errorf("expected one session in storage.list, got %d", storage.list.Len())
+		}
+	})
+	t.Run("returns nil for unknown session", func(t *testing.T) {
+		storage := newStorage()
+
+		got, err := storage.RegenerateSession("abcde", "fghij")
+
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+	})
+	t.Run("returns error for duplicated new id", func(t *testing.T) {
+		storage := newStorage()
+		storage.insertSession(newSession("abcde"))
+		storage.insertSession(newSession("fghij"))
+
+		_, err := storage.RegenerateSession("abcde", "fghij")
+
+		assert.Error(t, err)
+	})
+}
+
 func TestStorage_Deadline(t *testing.T) {
 
 	var err error
@@ -146,23 +206,30 @@ func TestStorage_Deadline(t *testing.T) {
 	storage := newStorage()
 
 	sess1 := newSession("abcde")
+	sess1.ct = sess1.ct.Add(-time.Second)
 	err = storage.insertSession(sess1)
 	assert.NoError(t, err)
 
 	sess2 := newSession("fghij")
+	sess2.ct = sess2.ct.Add(-time.Second)
 	err = storage.insertSession(sess2)
 	assert.NoError(t, err)
 
-	time.Sleep(time.Millisecond)
-
 	sess3 := newSession("klmno")
 	err = storage.insertSession(sess3)
 	assert.NoError(t, err)
 
 	t.Run("remove expired sessions only", func(t *testing.T) {
 
-		checker := stubMilliAgeChecker(1)
-		storage.Deadline(checker)
+		want := []sessionpkg.ExpiredSession{
+			{SessionInfo: sess1.info(), Lifetime: sessionpkg.LifetimeMaxAge},
+			{SessionInfo: sess2.info(), Lifetime: sessionpkg.LifetimeMaxAge},
+		}
+		checker := stubMilliAgeChecker(500)
+		reaped, err := storage.Deadline(checker, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, reaped, want)
 
 		if len(storage.sessions) > 1 {
 			t.Fatal("didn't remove expired sessions from storage.sessions")
@@ -184,7 +251,42 @@ func TestStorage_Deadline(t *testing.T) {
 			t.Errorf("the session(%s) isn't in storage.list", sess3.id)
 		}
 	})
+	t.Run("remove idle sessions", func(t *testing.T) {
+		storage := newStorage()
+
+		sess1 := newSession("abcde")
+		sess1.ct = sess1.ct.Add(-time.Second)
+		storage.insertSession(sess1)
+
+		sess2 := newSession("fghij")
+		sess2.at = sess2.at.Add(-time.Second)
+		storage.insertSession(sess2)
+
+		want := []sessionpkg.ExpiredSession{
+			{SessionInfo: sess2.info(), Lifetime: sessionpkg.LifetimeIdleTimeout},
+		}
+		reaped, err := storage.Deadline(stubMilliAgeChecker(5000), stubMilliAgeChecker(500))
+
+		assert.NoError(t, err)
+		assert.Equal(t, reaped, want)
+		if _, ok := storage.sessions[sess1.id]; !ok {
+			t.Errorf("the session(%s) isn't in storage.sessions", sess1.id)
+		}
+	})
+}
+
+func TestStorage_TouchSession(t *testing.T) {
+	storage := newStorage()
+	sess := newSession("abcde")
+	sess.at = sess.at.Add(-time.Second)
+	storage.insertSession(sess)
+
+	err := storage.TouchSession("abcde")
 
+	assert.NoError(t, err)
+	if time.Since(sess.at) > 100*time.Millisecond {
+		t.Errorf("didn't update access time, got %v", sess.at)
+	}
 }
 
 type stubMilliAgeChecker int64
@@ -192,3 +294,200 @@ type stubMilliAgeChecker int64
 func (c stubMilliAgeChecker) ShouldReap(t time.Time) bool {
 	return time.Now().UnixMilli()-t.UnixMilli() >= int64(c)
 }
+
+func TestSession_ConcurrentAccess(t *testing.T) {
+	sess := newSession("abcde")
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sess.Set("foo", i)
+			sess.Get("foo")
+			sess.Delete("bar")
+		}()
+	}
+	wg.Wait()
+
+	assert.NotNil(t, sess.Get("foo"))
+}
+
+func TestStorage_BindUser(t *testing.T) {
+	storage := newStorage()
+	for _, sid := range []string{"abcde", "fghij", "klmno"} {
+		storage.CreateSession(sid)
+	}
+
+	for sid, uid := range map[string]string{"abcde": "alex", "fghij": "alex", "klmno": "andre"} {
+		_, err := storage.BindUser(sid, uid, sessionpkg.UserLimit{})
+		assert.NoError(t, err)
+	}
+
+	t.Run("lists the user sessions", func(t *testing.T) {
+		got, err := storage.UserSessions("alex")
+
+		assert.NoError(t, err)
+		assert.Equal(t, got, []string{"abcde", "fghij"})
+	})
+	t.Run("returns error for unknown session", func(t *testing.T) {
+		_, err := storage.BindUser("pqrst", "alex", sessionpkg.UserLimit{})
+
+		assert.Equal(t, err, sessionpkg.ErrUnknownSessionId)
+	})
+	t.Run("moves the binding of regenerated session", func(t *testing.T) {
+		storage.RegenerateSession("fghij", "uvwxy")
+
+		got, _ := storage.UserSessions("alex")
+		assert.Equal(t, got, []string{"abcde", "uvwxy"})
+	})
+	t.Run("unbinds reaped session", func(t *testing.T) {
+		storage.ReapSession("abcde")
+
+		got, _ := storage.UserSessions("alex")
+		assert.Equal(t, got, []string{"uvwxy"})
+	})
+	t.Run("unbinds expired session", func(t *testing.T) {
+		storage.sessions["klmno"].Value.(*session).at = time.Now().Add(-time.Second)
+
+		storage.Deadline(nil, stubMilliAgeChecker(500))
+
+		got, _ := storage.UserSessions("andre")
+		assert.Equal(t, len(got), 0)
+		assert.Equal(t, len(storage.users), 1)
+	})
+	t.Run("unbinds session from empty user", func(t *testing.T) {
+		storage.BindUser("uvwxy", "", sessionpkg.UserLimit{})
+
+		got, _ := storage.UserSessions("alex")
+		assert.Equal(t, len(got), 0)
+		assert.Equal(t, len(storage.users), 0)
+	})
+}
+
+func TestStorage_BindUserLimit(t *testing.T) {
+	newStorageWithUser := func(sids ...string) *storage {
+		storage := newStorage()
+		for i, sid := range sids {
+			sess := newSession(sid)
+			sess.ct = sess.ct.Add(time.Duration(i) * time.Second)
+			storage.insertSession(sess)
+			storage.BindUser(sid, "alex", sessionpkg.UserLimit{})
+		}
+		return storage
+	}
+
+	t.Run("rejects session over the limit", func(t *testing.T) {
+		storage := newStorageWithUser("abcde", "fghij")
+		storage.CreateSession("klmno")
+
+		_, err := storage.BindUser("klmno", "alex", sessionpkg.UserLimit{Max: 2})
+
+		var limitErr *sessionpkg.SessionLimitError
+		if !errors.As(err, &limitErr) || limitErr.Max != 2 {
+			t.Errorf("didn't return limit error, got %v", err)
+		}
+		got, _ := storage.UserSessions("alex")
+		assert.Equal(t, got, []string{"abcde", "fghij"})
+	})
+	t.Run("evicts the oldest sessions", func(t *testing.T) {
+		storage := newStorageWithUser("fghij", "abcde", "klmno")
+		storage.CreateSession("pqrst")
+
+		evicted, err := storage.BindUser("pqrst", "alex", sessionpkg.UserLimit{Max: 2, EvictOldest: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, evicted, []string{"fghij", "abcde"})
+		got, _ := storage.UserSessions("alex")
+		assert.Equal(t, got, []string{"klmno", "pqrst"})
+		if _, ok := storage.sessions["fghij"]; ok {
+			t.Error("didn't remove evicted session")
+		}
+	})
+	t.Run("doesn't count session already bound", func(t *testing.T) {
+		storage := newStorageWithUser("abcde", "fghij")
+
+		_, err := storage.BindUser("fghij", "alex", sessionpkg.UserLimit{Max: 2})
+
+		assert.NoError(t, err)
+	})
+	t.Run("binds atomically", func(t *testing.T) {
+		storage := newStorage()
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sid := fmt.Sprint(i)
+				storage.CreateSession(sid)
+				storage.BindUser(sid, "alex", sessionpkg.UserLimit{Max: 3})
+			}()
+		}
+		wg.Wait()
+
+		got, _ := storage.UserSessions("alex")
+		assert.Equal(t, len(got), 3)
+	})
+}
+
+type spyMetrics struct {
+	sessionpkg.NopMetrics
+	ops  []string
+	errs int
+	live int
+}
+
+func (m *spyMetrics) LiveSessions(n int) {
+	m.live = n
+}
+
+func (m *spyMetrics) StorageOperation(op string, d time.Duration, err error) {
+	m.ops = append(m.ops, op)
+	if err != nil {
+		m.errs++
+	}
+}
+
+func TestStorage_SetMetrics(t *testing.T) {
+	storage := newStorage()
+	storage.insertSession(newSession("abcde"))
+	metrics := &spyMetrics{}
+
+	storage.SetMetrics(metrics)
+	assert.Equal(t, metrics.live, 1)
+
+	storage.CreateSession("fghij")
+	storage.RegenerateSession("abcde", "fghij")
+	storage.ReapSession("abcde")
+
+	assert.Equal(t, metrics.live, 1)
+	assert.Equal(t, len(metrics.ops), 3)
+	assert.Equal(t, metrics.ops[1], "RegenerateSession")
+	assert.Equal(t, metrics.errs, 1)
+}
+
+func TestStorage_ListSessions(t *testing.T) {
+	storage := newStorage()
+	old := newSession("abcde")
+	old.ct = old.ct.Add(-time.Second)
+	storage.insertSession(old)
+	storage.CreateSession("fghij")
+	storage.BindUser("fghij", "alex", sessionpkg.UserLimit{})
+
+	got, err := storage.ListSessions()
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got[0].ID, "abcde")
+	assert.Equal(t, got[0].Created, old.ct)
+	assert.Equal(t, got[1].ID, "fghij")
+	assert.Equal(t, got[1].UID, "alex")
+}
+
+func TestSession_Keys(t *testing.T) {
+	sess := newSession("abcde")
+	sess.Set("foo", 1)
+	sess.Set("bar", 2)
+
+	assert.Equal(t, sess.Keys(), []string{"bar", "foo"})
+}
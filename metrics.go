@@ -0,0 +1,111 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// Metrics receives the sessions activity, so it can be exported to a
+// monitoring system (see the prometheus package). The methods are called
+// concurrently, and shouldn't block.
+type Metrics interface {
+	// Called when a session is created.
+	SessionCreated()
+	// Called when a session is read.
+	SessionRead()
+	// Called when a session is destroyed.
+	SessionDestroyed()
+	// Called when an expired session is removed, with the event reason
+	// (LifetimeMaxAge or LifetimeIdleTimeout, see Manager.OnExpire()).
+	SessionExpired(reason string)
+	// Called with the number of sessions kept by the storage, whenever it
+	// may have changed.
+	LiveSessions(n int)
+	// Called when the expired sessions are removed by the GC routine,
+	// with how long it took and how many were removed.
+	GCRun(d time.Duration, reaped int)
+	// Called when a storage operation is done, with how long it took and
+	// its error (nil on success). The op is the Storage method name
+	// (e.g. "GetSession"), or "UpdateSession" when the session is written
+	// by its Set() or Delete() (e.g. by the filesystem storage).
+	StorageOperation(op string, d time.Duration, err error)
+}
+
+// MetricsReporter is implemented by providers and storages that report to
+// a Metrics (e.g. the provider returned by NewProvider(), which also sets
+// it to the storage).
+type MetricsReporter interface {
+	SetMetrics(m Metrics)
+}
+
+// NopMetrics ignores everything. It can be embedded by Metrics that only
+// need some of the methods.
+type NopMetrics struct{}
+
+func (NopMetrics) SessionCreated()                                        {}
+func (NopMetrics) SessionRead()                                           {}
+func (NopMetrics) SessionDestroyed()                                      {}
+func (NopMetrics) SessionExpired(reason string)                           {}
+func (NopMetrics) LiveSessions(n int)                                     {}
+func (NopMetrics) GCRun(d time.Duration, reaped int)                      {}
+func (NopMetrics) StorageOperation(op string, d time.Duration, err error) {}
+
+// Holds the Metrics, which can be replaced while it's reported to.
+type metrics struct {
+	mu sync.RWMutex
+	m  Metrics
+}
+
+func (mt *metrics) set(m Metrics) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	mt.m = m
+}
+
+// Returns the Metrics, or NopMetrics if it wasn't set.
+func (mt *metrics) get() Metrics {
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+	if mt.m == nil {
+		return NopMetrics{}
+	}
+	return mt.m
+}
+
+// Sets the Metrics that the sessions activity is reported to, and sets it
+// to the provider if it's a MetricsReporter. Setting nil stops reporting.
+//
+// The Manager reports the sessions created, read, destroyed and expired
+// (the last only when the provider is an EventSource).
+func (m *Manager) SetMetrics(mt Metrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.metrics.set(mt)
+	if r, ok := m.provider.(MetricsReporter); ok {
+		r.SetMetrics(mt)
+	}
+}
+
+// Reports the session lifecycle event.
+func (m *Manager) report(ev Event) {
+	mt := m.metrics.get()
+	switch ev.Type {
+	case EventCreated:
+		mt.SessionCreated()
+	case EventRead:
+		mt.SessionRead()
+	case EventDestroyed:
+		mt.SessionDestroyed()
+	case EventExpired:
+		mt.SessionExpired(ev.Reason)
+	}
+}
+
+// Sets the Metrics that the GC runs are reported to, and sets it to the
+// storage if it's a MetricsReporter. Setting nil stops reporting.
+func (p *defaultProvider) SetMetrics(mt Metrics) {
+	p.metrics.set(mt)
+	if r, ok := p.storage.(MetricsReporter); ok {
+		r.SetMetrics(mt)
+	}
+}
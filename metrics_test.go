@@ -0,0 +1,82 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+type stubMetricsStorage struct {
+	*stubSessionStorage
+	metrics Metrics
+}
+
+func (ss *stubMetricsStorage) SetMetrics(m Metrics) {
+	ss.metrics = m
+}
+
+func TestManager_SetMetrics(t *testing.T) {
+	t.Run("reports the sessions activity", func(t *testing.T) {
+		metrics := &spyMetrics{}
+		manager := NewManager(&stubProvider{}, "SessionID", 3600)
+		manager.SetMetrics(metrics)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+		manager.StartSession(res, req)
+		req.AddCookie(res.Result().Cookies()[0])
+		manager.StartSession(httptest.NewRecorder(), req)
+		manager.DestroySession(httptest.NewRecorder(), req)
+
+		assert.Equal(t, metrics.created, 1)
+		assert.Equal(t, metrics.read, 1)
+		assert.Equal(t, metrics.destroyed, 1)
+	})
+	t.Run("sets the metrics to the provider and the storage", func(t *testing.T) {
+		metrics := &spyMetrics{}
+		storage := &stubMetricsStorage{stubSessionStorage: newStubSessionStorage()}
+		manager := NewManager(NewProvider(storage, nil), "SessionID", 3600)
+		manager.SetMetrics(metrics)
+
+		if storage.metrics != metrics {
+			t.Error("didn't set the metrics to the storage")
+		}
+	})
+	t.Run("reports the expired sessions once", func(t *testing.T) {
+		metrics := &spyMetrics{}
+		storage := newStubSessionStorage()
+		provider := NewProvider(storage, func(maxAge int64) AgeChecker {
+			return stubMilliAgeChecker(maxAge)
+		})
+		manager := NewManager(provider, "SessionID", 5000)
+		manager.SetIdleTimeout(500)
+		manager.SetMetrics(metrics)
+
+		idle := newStubSession("17af450")
+		idle.AccessedAt = idle.AccessedAt.Add(-time.Second)
+		storage.Sessions[idle.Id] = idle
+
+		_, err := provider.SessionGC()
+		assert.NoError(t, err)
+
+		if len(metrics.expired) != 1 || metrics.expired[0] != LifetimeIdleTimeout {
+			t.Errorf("didn't report the expired session, got %v", metrics.expired)
+		}
+		assert.Equal(t, metrics.gcRuns, 1)
+		assert.Equal(t, metrics.reaped, 1)
+	})
+	t.Run("stops reporting when set to nil", func(t *testing.T) {
+		metrics := &spyMetrics{}
+		manager := NewManager(&stubProvider{}, "SessionID", 3600)
+		manager.SetMetrics(metrics)
+		manager.SetMetrics(nil)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		manager.StartSession(httptest.NewRecorder(), req)
+
+		assert.Equal(t, metrics.created, 0)
+	})
+}
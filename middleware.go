@@ -0,0 +1,163 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+type contextKey struct{}
+
+// Methods of requests that shouldn't modify the session.
+var safeMethods = map[string]bool{http.MethodGet: true, http.MethodHead: true, http.MethodOptions: true, http.MethodTrace: true}
+
+// Holds the request session, which can be replaced while the request
+// is handled (e.g. by RegenerateID()).
+type holder struct {
+	mu   sync.Mutex
+	sess Session
+}
+
+func (h *holder) get() Session {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sess
+}
+
+func (h *holder) set(sess Session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sess = sess
+}
+
+// Returns a copy of ctx carrying the session.
+func NewContext(ctx context.Context, sess Session) context.Context {
+	return context.WithValue(ctx, contextKey{}, &holder{sess: sess})
+}
+
+// Returns the session stored into ctx, or nil if there is none.
+func FromContext(ctx context.Context) Session {
+	if h, ok := ctx.Value(contextKey{}).(*holder); ok {
+		return h.get()
+	}
+	return nil
+}
+
+// Replaces the session stored into ctx, if there is one.
+func replaceInContext(ctx context.Context, sess Session) {
+	if h, ok := ctx.Value(contextKey{}).(*holder); ok {
+		h.set(sess)
+	}
+}
+
+// Saver is implemented by sessions that keep their state in the response
+// (e.g. client-side storages). The Manager middleware saves them before
+// the response header is written.
+type Saver interface {
+	Save(w http.ResponseWriter) error
+}
+
+// Calls commit once, right before the response header is written.
+type responseWriter struct {
+	http.ResponseWriter
+	once   sync.Once
+	commit func()
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.once.Do(w.commit)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.once.Do(w.commit)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) Flush() {
+	w.once.Do(w.commit)
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Saves the session before the connection is taken over (e.g. by a
+// websocket upgrade).
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.once.Do(w.commit)
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+func (w *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	w.once.Do(w.commit)
+	return io.Copy(w.ResponseWriter, src)
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Returns a http handler that starts the session once per request and
+// stores it into the request context, so next handler (and anything it
+// calls) can get it through FromContext().
+//
+// When the session cannot be started, it responds with internal server
+// error status and next handler isn't called.
+//
+// If the session implements Saver, it's saved before the response header
+// is written (or when next handler returns without writing anything). A
+// lazy session (see SetLazy()) is created at this point, if needed.
+//
+// When session locking is enabled (see SetLockTimeout()), the session is
+// locked before it's started and unlocked after it's saved. If the lock
+// isn't acquired in time, it responds with service unavailable status.
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	if next == nil {
+		panic("nil handler")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		unlock, err := m.lockRequest(r)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		defer unlock()
+		sess, err := m.StartSessionE(w, r)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if l, ok := sess.(*lazySession); ok {
+			l.mu.Lock()
+			l.deferred = true
+			l.mu.Unlock()
+		}
+		ctx := NewContext(r.Context(), sess)
+		rw := &responseWriter{ResponseWriter: w}
+		rw.commit = func() {
+			sess := FromContext(ctx)
+			if s, ok := sess.(Saver); ok {
+				if err := s.Save(w); err != nil {
+					m.logger.get().Error("session: unable to save the session", sidAttr(sess.SessionID()), "err", err)
+				}
+			}
+			if l, ok := sess.(*lazySession); ok {
+				l.mu.Lock()
+				l.committed = true
+				l.mu.Unlock()
+			}
+		}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+		rw.once.Do(rw.commit)
+	})
+}
@@ -0,0 +1,184 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	t.Run("returns stored session", func(t *testing.T) {
+		sess := newStubSession("abcde")
+		ctx := NewContext(context.Background(), sess)
+
+		got := FromContext(ctx)
+
+		assert.NotNil(t, got)
+		assert.Equal(t, got.SessionID(), sess.SessionID())
+	})
+	t.Run("returns nil without session", func(t *testing.T) {
+		got := FromContext(context.Background())
+
+		assert.Nil(t, got)
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	provider := &stubProvider{}
+	manager := NewManager(provider, "SessionID", 3600)
+
+	t.Run("puts the session into the request context", func(t *testing.T) {
+		var got Session
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = FromContext(r.Context())
+		}))
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+
+		handler.ServeHTTP(res, req)
+
+		assert.NotNil(t, got)
+		if _, ok := provider.Sessions[got.SessionID()]; !ok {
+			t.Error("didn't start the session through the provider")
+		}
+		assert.NotNil(t, getCookieFromResponse(res))
+	})
+	t.Run("responds with internal server error when fail to start session", func(t *testing.T) {
+		manager := NewManager(&stubFailingProvider{}, "SessionID", 3600)
+		called := false
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, res.Code, http.StatusInternalServerError)
+		if called {
+			t.Error("didn't expect next handler to be called")
+		}
+	})
+	t.Run("saves the session before writing the response", func(t *testing.T) {
+		sess := &stubSavingSession{stubSession: newStubSession("abcde")}
+		manager := NewManager(&stubProvider{}, "SessionID", 3600)
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			replaceInContext(r.Context(), sess)
+			if sess.saved != 0 {
+				t.Error("saved the session too early")
+			}
+			w.Write([]byte("ok"))
+			w.Write([]byte("ok"))
+		}))
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, sess.saved, 1)
+		assert.Equal(t, res.Header().Get("X-Saved"), "abcde")
+	})
+	t.Run("saves the session when nothing is written", func(t *testing.T) {
+		sess := &stubSavingSession{stubSession: newStubSession("abcde")}
+		manager := NewManager(&stubProvider{}, "SessionID", 3600)
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			replaceInContext(r.Context(), sess)
+		}))
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+
+		handler.ServeHTTP(res, req)
+
+		assert.Equal(t, sess.saved, 1)
+	})
+	t.Run("replaces the context session on regenerate", func(t *testing.T) {
+		var before, after, regenerated Session
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			before = FromContext(r.Context())
+			regenerated, _ = manager.RegenerateID(w, r)
+			after = FromContext(r.Context())
+		}))
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+
+		handler.ServeHTTP(res, req)
+
+		assert.NotNil(t, regenerated)
+		assert.Equal(t, after, regenerated)
+		if before == after {
+			t.Error("didn't replace the session")
+		}
+	})
+	t.Run("regenerates the context session with a stale cookie", func(t *testing.T) {
+		manager := NewManager(NewProvider(newStubSessionStorage(), nil), "SessionID", 3600)
+		stale, _ := manager.sessionID()
+		var started, regenerated Session
+		handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started = FromContext(r.Context())
+			started.Set("cart", "foo")
+			regenerated, _ = manager.RegenerateID(w, r)
+		}))
+
+		req, _ := http.NewRequest(http.MethodPost, dummySite, nil)
+		req.AddCookie(&http.Cookie{Name: "SessionID", Value: url.QueryEscape(stale)})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.NotNil(t, regenerated)
+		assert.Equal(t, regenerated.Get("cart"), any("foo"))
+		if regenerated.SessionID() == started.SessionID() {
+			t.Error("didn't change the session id")
+		}
+	})
+	t.Run("hijacks the connection", func(t *testing.T) {
+		sess := &stubSavingSession{stubSession: newStubSession("abcde")}
+		manager := NewManager(&stubProvider{}, "SessionID", 3600)
+		server := httptest.NewServer(manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			replaceInContext(r.Context(), sess)
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("didn't expect error, got %v", err)
+				return
+			}
+			defer conn.Close()
+			buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+			buf.Flush()
+		})))
+		defer server.Close()
+
+		res, err := http.Get(server.URL)
+
+		assert.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, res.StatusCode, http.StatusSwitchingProtocols)
+		assert.Equal(t, sess.saved, 1)
+	})
+	t.Run("panic on nil handler", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if r != "nil handler" {
+				t.Errorf("didn't get expected panic, got: %v", r)
+			}
+		}()
+		manager.Middleware(nil)
+	})
+}
+
+type stubSavingSession struct {
+	*stubSession
+	saved int
+}
+
+func (s *stubSavingSession) Save(w http.ResponseWriter) error {
+	s.saved++
+	w.Header().Set("X-Saved", s.SessionID())
+	return nil
+}
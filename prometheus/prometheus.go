@@ -0,0 +1,215 @@
+// Package prometheus exports the sessions metrics (see session.Metrics) in
+// the Prometheus text format, without the Prometheus client library.
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sessionpkg "github.com/xandalm/go-session"
+)
+
+// Upper bounds, in seconds, of the duration histograms buckets.
+var buckets = []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64 // by bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets))
+	}
+	v := d.Seconds()
+	if i, _ := slices.BinarySearch(buckets, v); i < len(buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+type operation struct {
+	duration histogram
+	errors   uint64
+}
+
+// Metrics implements session.Metrics, and serves them in the Prometheus
+// text format as a http handler.
+type Metrics struct {
+	mu        sync.Mutex
+	created   uint64
+	read      uint64
+	destroyed uint64
+	expired   map[string]uint64 // by reason
+	live      int
+	gc        histogram
+	reaped    uint64
+	ops       map[string]*operation // by storage operation
+}
+
+var _ sessionpkg.Metrics = (*Metrics)(nil)
+
+// Returns a new Metrics, to be set to the Manager (see
+// session.Manager.SetMetrics()) and served by the http server.
+//
+//	metrics := prometheus.New()
+//	manager.SetMetrics(metrics)
+//	http.Handle("/metrics", metrics)
+func New() *Metrics {
+	return &Metrics{
+		expired: map[string]uint64{},
+		ops:     map[string]*operation{},
+	}
+}
+
+func (m *Metrics) SessionCreated() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.created++
+}
+
+func (m *Metrics) SessionRead() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.read++
+}
+
+func (m *Metrics) SessionDestroyed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.destroyed++
+}
+
+func (m *Metrics) SessionExpired(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expired[reason]++
+}
+
+func (m *Metrics) LiveSessions(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.live = n
+}
+
+func (m *Metrics) GCRun(d time.Duration, reaped int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gc.observe(d)
+	m.reaped += uint64(reaped)
+}
+
+func (m *Metrics) StorageOperation(op string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.ops[op]
+	if !ok {
+		o = &operation{}
+		m.ops[op] = o
+	}
+	o.duration.observe(d)
+	if err != nil {
+		o.errors++
+	}
+}
+
+// Writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// Writes the metrics in the Prometheus text format to w, returning the
+// number of bytes written and the first write error.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := &encoder{w: w}
+	e.header("session_created_total", "counter", "Sessions created.")
+	e.sample("session_created_total", "", float64(m.created))
+	e.header("session_read_total", "counter", "Sessions read.")
+	e.sample("session_read_total", "", float64(m.read))
+	e.header("session_destroyed_total", "counter", "Sessions destroyed.")
+	e.sample("session_destroyed_total", "", float64(m.destroyed))
+	e.header("session_expired_total", "counter", "Expired sessions removed, by reason.")
+	for _, reason := range slices.Sorted(maps.Keys(m.expired)) {
+		e.sample("session_expired_total", label("reason", reason), float64(m.expired[reason]))
+	}
+	e.header("session_live", "gauge", "Sessions kept by the storage.")
+	e.sample("session_live", "", float64(m.live))
+	e.header("session_gc_duration_seconds", "histogram", "Duration of the GC runs.")
+	e.histogram("session_gc_duration_seconds", "", &m.gc)
+	e.header("session_gc_reaped_total", "counter", "Expired sessions removed by the GC runs.")
+	e.sample("session_gc_reaped_total", "", float64(m.reaped))
+
+	ops := slices.Sorted(maps.Keys(m.ops))
+	e.header("session_storage_operation_duration_seconds", "histogram", "Duration of the storage operations.")
+	for _, op := range ops {
+		e.histogram("session_storage_operation_duration_seconds", label("op", op), &m.ops[op].duration)
+	}
+	e.header("session_storage_operation_errors_total", "counter", "Failed storage operations.")
+	for _, op := range ops {
+		e.sample("session_storage_operation_errors_total", label("op", op), float64(m.ops[op].errors))
+	}
+	return e.n, e.err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+// Writes the text format, keeping the first error.
+type encoder struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (e *encoder) printf(format string, args ...any) {
+	if e.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(e.w, format, args...)
+	e.n += int64(n)
+	e.err = err
+}
+
+func (e *encoder) header(name, typ, help string) {
+	e.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (e *encoder) sample(name, labels string, v float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	e.printf("%s%s %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func (e *encoder) histogram(name, labels string, h *histogram) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	var cumulative uint64
+	for i, le := range buckets {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		e.sample(name+"_bucket", labels+sep+label("le", strconv.FormatFloat(le, 'g', -1, 64)), float64(cumulative))
+	}
+	e.sample(name+"_bucket", labels+sep+label("le", "+Inf"), float64(h.count))
+	e.sample(name+"_sum", labels, h.sum)
+	e.sample(name+"_count", labels, float64(h.count))
+}
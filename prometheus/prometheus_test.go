@@ -0,0 +1,64 @@
+package prometheus
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := New()
+	metrics.SessionCreated()
+	metrics.SessionCreated()
+	metrics.SessionRead()
+	metrics.SessionDestroyed()
+	metrics.SessionExpired("max age")
+	metrics.SessionExpired("idle timeout")
+	metrics.LiveSessions(7)
+	metrics.GCRun(3*time.Millisecond, 2)
+	metrics.StorageOperation("GetSession", 20*time.Millisecond, nil)
+	metrics.StorageOperation("GetSession", 2*time.Second, errors.New("foo"))
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	res := httptest.NewRecorder()
+	metrics.ServeHTTP(res, req)
+
+	assert.Equal(t, res.Code, http.StatusOK)
+	if ct := res.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("didn't set the text format content type, got %q", ct)
+	}
+	body := res.Body.String()
+	for _, line := range []string{
+		"# TYPE session_created_total counter",
+		"session_created_total 2",
+		"session_read_total 1",
+		"session_destroyed_total 1",
+		`session_expired_total{reason="max age"} 1`,
+		`session_expired_total{reason="idle timeout"} 1`,
+		"# TYPE session_live gauge",
+		"session_live 7",
+		`session_gc_duration_seconds_bucket{le="0.001"} 0`,
+		`session_gc_duration_seconds_bucket{le="0.005"} 1`,
+		`session_gc_duration_seconds_bucket{le="+Inf"} 1`,
+		"session_gc_duration_seconds_count 1",
+		"session_gc_reaped_total 2",
+		`session_storage_operation_duration_seconds_bucket{op="GetSession",le="0.025"} 1`,
+		`session_storage_operation_duration_seconds_bucket{op="GetSession",le="2.5"} 2`,
+		`session_storage_operation_duration_seconds_sum{op="GetSession"} 2.02`,
+		`session_storage_operation_duration_seconds_count{op="GetSession"} 2`,
+		`session_storage_operation_errors_total{op="GetSession"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("didn't write %q, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	assert.Equal(t, label("op", "a\"b\\c\nd"), `op="a\"b\\c\nd"`)
+}
@@ -2,6 +2,8 @@ package session
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 )
 
@@ -14,7 +16,68 @@ type Storage interface {
 	GetSession(sid string) (Session, error)
 	ContainsSession(sid string) (bool, error)
 	ReapSession(sid string) error
-	Deadline(AgeChecker)
+	RegenerateSession(oldSid, newSid string) (Session, error)
+	// Updates the session access time, or does nothing if the session
+	// doesn't exist.
+	TouchSession(sid string) error
+	// Removes the sessions expired accordingly to the creation time
+	// (absolute) or the access time (idle), returning them with the
+	// lifetime that expired (see ExpiredLifetime()). When some of them
+	// cannot be removed, also returns an error. Any checker can be nil,
+	// which disables it.
+	Deadline(absolute, idle AgeChecker) ([]ExpiredSession, error)
+	// Binds the session to the user identifier, or unbinds it when uid is
+	// empty. When the user would hold more sessions than the limit, the
+	// oldest ones (by creation time) are removed and returned if the limit
+	// evicts them, or a *SessionLimitError is returned otherwise. Both the
+	// check and the binding must happen atomically.
+	//
+	// Returns ErrUnknownSessionId if the session doesn't exist, or
+	// ErrNotSupported if the storage cannot index the sessions by user.
+	BindUser(sid, uid string, limit UserLimit) ([]string, error)
+	// Returns the identifiers of the sessions bound to the user, or
+	// ErrNotSupported if the storage cannot index the sessions by user.
+	// The removed sessions (e.g. through ReapSession() or Deadline()) are
+	// no longer bound to it.
+	UserSessions(uid string) ([]string, error)
+	// Returns the sessions kept by the storage, sorted by creation time,
+	// including the expired ones not removed yet. Returns ErrNotSupported
+	// if the storage cannot enumerate them.
+	ListSessions() ([]SessionInfo, error)
+}
+
+// SessionInfo describes a session kept by the storage.
+type SessionInfo struct {
+	ID       string
+	Created  time.Time
+	Accessed time.Time // last write, or last read with an idle timeout
+	UID      string    // bound user identifier, if any
+}
+
+// Lifetimes of the sessions, which tell why an expired session was
+// removed.
+const (
+	LifetimeMaxAge      = "max age"      // since the creation
+	LifetimeIdleTimeout = "idle timeout" // since the last access
+)
+
+// ExpiredSession describes a session removed by Storage.Deadline().
+type ExpiredSession struct {
+	SessionInfo
+	Lifetime string // LifetimeMaxAge or LifetimeIdleTimeout
+}
+
+// Returns which lifetime expired accordingly to the creation time
+// (absolute) or the access time (idle), or empty if none. Any checker can
+// be nil, which disables it.
+func ExpiredLifetime(absolute, idle AgeChecker, created, accessed time.Time) string {
+	switch {
+	case absolute != nil && absolute.ShouldReap(created):
+		return LifetimeMaxAge
+	case idle != nil && idle.ShouldReap(accessed):
+		return LifetimeIdleTimeout
+	}
+	return ""
 }
 
 type AgeCheckerAdapter func(int64) AgeChecker
@@ -22,8 +85,7 @@ type AgeCheckerAdapter func(int64) AgeChecker
 type secondsAgeChecker int64
 
 func (ma secondsAgeChecker) ShouldReap(t time.Time) bool {
-	diff := time.Now().Unix() - t.Unix()
-	return diff >= int64(ma)
+	return time.Since(t) >= time.Duration(ma)*time.Second
 }
 
 var SecondsAgeCheckerAdapter AgeCheckerAdapter = func(maxAge int64) AgeChecker {
@@ -34,9 +96,19 @@ var SecondsAgeCheckerAdapter AgeCheckerAdapter = func(maxAge int64) AgeChecker {
 type defaultProvider struct {
 	storage           Storage
 	ageCheckerAdapter AgeCheckerAdapter
+	mu                sync.RWMutex // guards strict, maxAge and idle
+	strict            bool
+	validateID        IDValidator
+	maxAge            int64
+	idle              int64
+	events            events
+	metrics           metrics
+	logger            logger
 }
 
 // Returns a new defaultProvider (address for pointer reference).
+//
+// The provider is in strict mode, see SetStrict().
 func NewProvider(storage Storage, adapter AgeCheckerAdapter) *defaultProvider {
 	if storage == nil {
 		panic("nil storage")
@@ -45,24 +117,82 @@ func NewProvider(storage Storage, adapter AgeCheckerAdapter) *defaultProvider {
 		adapter = SecondsAgeCheckerAdapter
 	}
 	return &defaultProvider{
-		storage,
-		adapter,
+		storage:           storage,
+		ageCheckerAdapter: adapter,
+		strict:            true,
 	}
 }
 
+// Sets the sessions lifetime, both adapted accordingly to the
+// AgeCheckerAdapter. The maxAge is counted from the session creation,
+// while idle is counted from the last access (see SessionRead()). Zero
+// disables any of them, and idle is disabled by default.
+//
+// The Manager sets them when created (see NewManager() and
+// Manager.SetIdleTimeout()).
+func (p *defaultProvider) SetExpiration(maxAge, idle int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxAge = maxAge
+	p.idle = idle
+}
+
+// Returns the checkers for the lifetime since creation (absolute) and
+// since last access (idle), which are nil when disabled.
+func (p *defaultProvider) ageCheckers() (absolute, idle AgeChecker) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.maxAge > 0 {
+		absolute = p.ageCheckerAdapter(p.maxAge)
+	}
+	if p.idle > 0 {
+		idle = p.ageCheckerAdapter(p.idle)
+	}
+	return
+}
+
+func (p *defaultProvider) expired(sess Session) bool {
+	return p.expiration(sess) != ""
+}
+
+// Returns which lifetime of the session expired, or empty if none.
+func (p *defaultProvider) expiration(sess Session) string {
+	absolute, idle := p.ageCheckers()
+	return ExpiredLifetime(absolute, idle, sess.CreationTime(), sess.AccessTime())
+}
+
+// Registers a hook to be called with all the session events (created,
+// read, destroyed, expired and regenerated).
+func (p *defaultProvider) Subscribe(fn Hook) {
+	p.events.onAll(fn)
+}
+
+// Sets the strict mode. In strict mode, SessionRead() doesn't create a
+// session for an unknown identifier, returning ErrUnknownSessionId
+// instead. This way, the client cannot choose it's own identifier.
+func (p *defaultProvider) SetStrict(strict bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.strict = strict
+}
+
 var (
 	ErrEmptySessionId             error = errors.New("session: sid cannot be empty")
+	ErrUnknownSessionId           error = errors.New("session: unknown sid")
 	ErrDuplicatedSessionId        error = errors.New("session: cannot duplicate sid")
 	ErrUnableToRestoreSession     error = errors.New("session: unable to restore session (storage failure)")
 	ErrUnableToEnsureNonDuplicity error = errors.New("session: unable to ensure non-duplicity of sid (storage failure)")
 	ErrUnableToDestroySession     error = errors.New("session: unable to destroy session (storage failure)")
 	ErrUnableToSaveSession        error = errors.New("session: unable to save session (storage failure)")
+	ErrUnableToRegenerateSession  error = errors.New("session: unable to regenerate session (storage failure)")
+	ErrNotSupported               error = errors.New("session: operation not supported by the storage")
 )
 
 // Creates a session with the given session identifier.
 //
 // Returns an error when:
 // - The identifier cannot be empty;
+// - The identifier is malformed;
 // - Cannot check if the identifier is already in use;
 // - The given identifier already exists;
 // - Session cannot be created through the storage api.
@@ -72,9 +202,13 @@ func (p *defaultProvider) SessionInit(sid string) (Session, error) {
 	if sid == "" {
 		return nil, ErrEmptySessionId
 	}
+	if !p.validID(sid) {
+		return nil, ErrInvalidSessionId
+	}
 	contains, err := p.storage.ContainsSession(sid)
 
 	if err != nil {
+		p.logStorageError("ContainsSession", sid, err)
 		return nil, ErrUnableToEnsureNonDuplicity
 	}
 	if contains {
@@ -82,41 +216,243 @@ func (p *defaultProvider) SessionInit(sid string) (Session, error) {
 	}
 	sess, err := p.storage.CreateSession(sid)
 	if err != nil {
+		p.logStorageError("CreateSession", sid, err)
 		return nil, ErrUnableToSaveSession
 	}
+	if sess != nil {
+		p.events.emit(sessionEvent(EventCreated, sess))
+	}
 	return sess, nil
 }
 
 // Restores the session accordingly to given session identifier. If
-// the session does not exists, then will create through SessionInit().
+// the session does not exists, then will create through SessionInit(),
+// unless the provider is in strict mode. An expired session (see
+// SetExpiration()) is removed and treated as one that does not exists,
+// regardless of the GC routine. When the idle timeout is set, the
+// session access time is updated.
 //
-// Returns error when cannot get session through storage api, or cannot
-// create one. Otherwise, will return the session.
+// Returns error when the identifier is malformed, cannot get session
+// through storage api, cannot remove the expired one, cannot create one
+// or the session does not exists in strict mode (ErrUnknownSessionId).
+// Otherwise, will return the session.
 func (p *defaultProvider) SessionRead(sid string) (Session, error) {
+	if !p.validID(sid) {
+		return nil, ErrInvalidSessionId
+	}
 	sess, err := p.storage.GetSession(sid)
 	if err != nil {
+		p.logStorageError("GetSession", sid, err)
 		return nil, ErrUnableToRestoreSession
 	}
+	if sess != nil {
+		if reason := p.expiration(sess); reason != "" {
+			if err := p.storage.ReapSession(sid); err != nil {
+				p.logStorageError("ReapSession", sid, err)
+				return nil, ErrUnableToDestroySession
+			}
+			ev := sessionEvent(EventExpired, sess)
+			ev.Reason = reason
+			p.events.emit(ev)
+			sess = nil
+		}
+	}
 	if sess == nil {
+		p.mu.RLock()
+		strict := p.strict
+		p.mu.RUnlock()
+		if strict {
+			return nil, ErrUnknownSessionId
+		}
 		sess, err = p.SessionInit(sid)
 		return sess, err
 	}
+	p.mu.RLock()
+	idle := p.idle
+	p.mu.RUnlock()
+	if idle > 0 {
+		if err := p.storage.TouchSession(sid); err != nil {
+			p.logStorageError("TouchSession", sid, err)
+			return nil, ErrUnableToRestoreSession
+		}
+	}
+	p.events.emit(sessionEvent(EventRead, sess))
 	return sess, nil
 }
 
 // Destroys the session.
 //
-// Returns error when cannot remove through storage api.
+// Returns error when the identifier is malformed or cannot remove
+// through storage api.
 func (p *defaultProvider) SessionDestroy(sid string) error {
+	return p.SessionRevoke(sid, "", "")
+}
+
+// Destroys the session like SessionDestroy(), emitting the destroyed event
+// with the user identifier and the reason, when not empty.
+func (p *defaultProvider) SessionRevoke(sid, uid, reason string) error {
+	if !p.validID(sid) {
+		return ErrInvalidSessionId
+	}
+	ev := Event{Type: EventDestroyed, SID: sid}
+	// only for the event timestamps, so it's destroyed anyway
+	if sess, err := p.storage.GetSession(sid); err == nil && sess != nil {
+		ev = sessionEvent(EventDestroyed, sess)
+	}
 	err := p.storage.ReapSession(sid)
 	if err != nil {
+		p.logStorageError("ReapSession", sid, err)
 		return ErrUnableToDestroySession
 	}
+	if uid != "" {
+		ev.UID = uid
+	}
+	ev.Reason = reason
+	p.events.emit(ev)
 	return nil
 }
 
+// Moves the session data from the old to the new session identifier.
+// If there is no session for the old identifier, then will create
+// through SessionInit().
+//
+// Returns an error when:
+// - The new identifier is empty;
+// - Some identifier is malformed;
+// - Cannot check if the new identifier is already in use;
+// - The new identifier already exists;
+// - Session cannot be moved through the storage api.
+//
+// Otherwise, will return the session under the new identifier.
+func (p *defaultProvider) SessionRegenerate(oldSid, newSid string) (Session, error) {
+	if newSid == "" {
+		return nil, ErrEmptySessionId
+	}
+	if !p.validID(oldSid) || !p.validID(newSid) {
+		return nil, ErrInvalidSessionId
+	}
+	contains, err := p.storage.ContainsSession(newSid)
+	if err != nil {
+		p.logStorageError("ContainsSession", newSid, err)
+		return nil, ErrUnableToEnsureNonDuplicity
+	}
+	if contains {
+		return nil, ErrDuplicatedSessionId
+	}
+	sess, err := p.storage.RegenerateSession(oldSid, newSid)
+	if err != nil {
+		p.logStorageError("RegenerateSession", oldSid, err)
+		return nil, ErrUnableToRegenerateSession
+	}
+	if sess == nil {
+		return p.SessionInit(newSid)
+	}
+	ev := sessionEvent(EventRegenerated, sess)
+	ev.OldSID = oldSid
+	p.events.emit(ev)
+	return sess, nil
+}
+
 // Checks for expired sessions through storage api, and remove them.
-// The maxAge will be adapted accordingly to AgeCheckerAdapter
-func (p *defaultProvider) SessionGC(maxAge int64) {
-	p.storage.Deadline(p.ageCheckerAdapter(maxAge))
+// The lifetime (see SetExpiration()) will be adapted accordingly to
+// AgeCheckerAdapter.
+//
+// Returns the number of removed sessions and, when some of them cannot
+// be removed, an error wrapping ErrUnableToDestroySession and the
+// storage error.
+func (p *defaultProvider) SessionGC() (int, error) {
+	start := time.Now()
+	reaped, err := p.storage.Deadline(p.ageCheckers())
+	p.metrics.get().GCRun(time.Since(start), len(reaped))
+	for _, e := range reaped {
+		p.events.emit(Event{
+			Type:     EventExpired,
+			SID:      e.ID,
+			Created:  e.Created,
+			Accessed: e.Accessed,
+			UID:      e.UID,
+			Reason:   e.Lifetime,
+		})
+	}
+	if err != nil {
+		return len(reaped), fmt.Errorf("%w: %w", ErrUnableToDestroySession, err)
+	}
+	return len(reaped), nil
+}
+
+// Binds the session to the user identifier, or unbinds it when uid is
+// empty (see UserSessions()), returning the user sessions evicted by the
+// limit. With a limit, the expired user sessions are removed first, so
+// they don't count.
+//
+// Returns the *SessionLimitError when the user holds the limit, or error
+// when the identifier is malformed or, wrapping ErrUnableToSaveSession
+// and the storage error, when cannot bind through storage api (e.g.
+// ErrUnknownSessionId or ErrNotSupported).
+func (p *defaultProvider) SessionBindUser(sid, uid string, limit UserLimit) ([]string, error) {
+	if !p.validID(sid) {
+		return nil, ErrInvalidSessionId
+	}
+	if limit.Max > 0 && uid != "" {
+		if err := p.reapExpiredUserSessions(uid); err != nil {
+			return nil, err
+		}
+	}
+	evicted, err := p.storage.BindUser(sid, uid, limit)
+	if errors.Is(err, ErrSessionLimit) {
+		return nil, err
+	}
+	if err != nil {
+		return evicted, fmt.Errorf("%w: %w", ErrUnableToSaveSession, err)
+	}
+	return evicted, nil
+}
+
+func (p *defaultProvider) reapExpiredUserSessions(uid string) error {
+	sids, err := p.storage.UserSessions(uid)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnableToSaveSession, err)
+	}
+	for _, sid := range sids {
+		sess, err := p.storage.GetSession(sid)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrUnableToRestoreSession, err)
+		}
+		if sess != nil {
+			if reason := p.expiration(sess); reason != "" {
+				if err := p.storage.ReapSession(sid); err != nil {
+					return fmt.Errorf("%w: %w", ErrUnableToDestroySession, err)
+				}
+				ev := sessionEvent(EventExpired, sess)
+				ev.UID = uid
+				ev.Reason = reason
+				p.events.emit(ev)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the identifiers of the sessions bound to the user, leaving out
+// the expired ones.
+//
+// Returns an error, wrapping ErrUnableToRestoreSession and the storage
+// error, when cannot get the sessions through storage api (e.g.
+// ErrNotSupported).
+func (p *defaultProvider) UserSessions(uid string) ([]string, error) {
+	sids, err := p.storage.UserSessions(uid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnableToRestoreSession, err)
+	}
+	var active []string
+	for _, sid := range sids {
+		sess, err := p.storage.GetSession(sid)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnableToRestoreSession, err)
+		}
+		if sess != nil && !p.expired(sess) {
+			active = append(active, sid)
+		}
+	}
+	return active, nil
 }
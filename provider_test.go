@@ -1,6 +1,7 @@
 package session
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -15,7 +16,7 @@ func TestSessionInit(t *testing.T) {
 	t.Run("tell storage to create session", func(t *testing.T) {
 		sessionStorage := &spySessionStorage{}
 
-		provider := &defaultProvider{sessionStorage, dummyAdapter}
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
 
 		_, err := provider.SessionInit("1")
 		assert.NoError(t, err)
@@ -27,7 +28,7 @@ func TestSessionInit(t *testing.T) {
 
 	sessionStorage := newStubSessionStorage()
 
-	provider := &defaultProvider{sessionStorage, dummyAdapter}
+	provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
 	t.Run("init the session", func(t *testing.T) {
 
 		sid := "17af454"
@@ -59,7 +60,7 @@ func TestSessionInit(t *testing.T) {
 				},
 			},
 		}
-		provider := &defaultProvider{sessionStorage, dummyAdapter}
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
 
 		_, err := provider.SessionInit("17af454")
 
@@ -72,7 +73,7 @@ func TestSessionInit(t *testing.T) {
 			GetSessionFunc:      func(sid string) (Session, error) { return nil, nil },
 			ContainsSessionFunc: func(sid string) (bool, error) { return false, nil },
 		}
-		provider := &defaultProvider{sessionStorage, dummyAdapter}
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
 
 		_, err := provider.SessionInit("17af450")
 
@@ -80,12 +81,36 @@ func TestSessionInit(t *testing.T) {
 	})
 }
 
+func TestProviderIDValidation(t *testing.T) {
+	sessionStorage := &spySessionStorage{}
+	provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
+	provider.SetIDValidator(DefaultIDValidator)
+
+	sid := "../17af454"
+
+	_, err := provider.SessionInit(sid)
+	assert.Equal(t, err, ErrInvalidSessionId)
+
+	_, err = provider.SessionRead(sid)
+	assert.Equal(t, err, ErrInvalidSessionId)
+
+	_, err = provider.SessionRegenerate(sid, "17af450")
+	assert.Equal(t, err, ErrInvalidSessionId)
+
+	err = provider.SessionDestroy(sid)
+	assert.Equal(t, err, ErrInvalidSessionId)
+
+	if *sessionStorage != (spySessionStorage{}) {
+		t.Errorf("didn't expect storage calls, got %+v", *sessionStorage)
+	}
+}
+
 func TestSessionRead(t *testing.T) {
 
 	t.Run("tell storage to get session", func(t *testing.T) {
 		sessionStorage := &spySessionStorage{}
 
-		provider := &defaultProvider{sessionStorage, dummyAdapter}
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
 
 		_, err := provider.SessionRead("1")
 		assert.NoError(t, err)
@@ -103,7 +128,7 @@ func TestSessionRead(t *testing.T) {
 		},
 	}
 
-	provider := &defaultProvider{sessionStorage, dummyAdapter}
+	provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
 
 	t.Run("returns session", func(t *testing.T) {
 		sid := "17af454"
@@ -127,9 +152,112 @@ func TestSessionRead(t *testing.T) {
 			t.Errorf("didn't get expected session, got %s but want %s", session.SessionID(), sid)
 		}
 	})
+	t.Run("returns error for unknown sid in strict mode", func(t *testing.T) {
+		provider := NewProvider(sessionStorage, dummyAdapter)
+
+		session, err := provider.SessionRead("17af000")
+
+		assert.Nil(t, session)
+		assert.Equal(t, err, ErrUnknownSessionId)
+
+		if _, ok := sessionStorage.Sessions["17af000"]; ok {
+			t.Error("didn't expect session to be created")
+		}
+	})
+	t.Run("treats expired session as unknown", func(t *testing.T) {
+		sessionStorage := newStubSessionStorage()
+		provider := NewProvider(sessionStorage, func(maxAge int64) AgeChecker {
+			return stubMilliAgeChecker(maxAge)
+		})
+		provider.SetExpiration(500, 0)
+
+		expired := newStubSession("17af450")
+		expired.CreatedAt = expired.CreatedAt.Add(-time.Second)
+		sessionStorage.Sessions[expired.Id] = expired
+
+		session, err := provider.SessionRead(expired.Id)
+
+		assert.Nil(t, session)
+		assert.Equal(t, err, ErrUnknownSessionId)
+		if _, ok := sessionStorage.Sessions[expired.Id]; ok {
+			t.Error("didn't remove expired session")
+		}
+	})
+	t.Run("treats idle session as unknown", func(t *testing.T) {
+		sessionStorage := newStubSessionStorage()
+		provider := NewProvider(sessionStorage, func(maxAge int64) AgeChecker {
+			return stubMilliAgeChecker(maxAge)
+		})
+		provider.SetExpiration(5000, 500)
+
+		idle := newStubSession("17af450")
+		idle.AccessedAt = idle.AccessedAt.Add(-time.Second)
+		sessionStorage.Sessions[idle.Id] = idle
+
+		_, err := provider.SessionRead(idle.Id)
+
+		assert.Equal(t, err, ErrUnknownSessionId)
+	})
+	t.Run("starts new session for expired one out of strict mode", func(t *testing.T) {
+		sessionStorage := newStubSessionStorage()
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: func(maxAge int64) AgeChecker {
+			return stubMilliAgeChecker(maxAge)
+		}, maxAge: 500}
+
+		expired := newStubSession("17af450")
+		expired.CreatedAt = expired.CreatedAt.Add(-time.Second)
+		sessionStorage.Sessions[expired.Id] = expired
+
+		session, err := provider.SessionRead(expired.Id)
+
+		assert.NoError(t, err)
+		if session == Session(expired) {
+			t.Error("didn't start new session")
+		}
+	})
+	t.Run("returns error when fail to remove expired session", func(t *testing.T) {
+		sessionStorage := &mockSessionStorage{
+			GetSessionFunc: func(sid string) (Session, error) {
+				sess := newStubSession(sid)
+				sess.CreatedAt = sess.CreatedAt.Add(-time.Second)
+				return sess, nil
+			},
+			ReapSessionFunc: func(sid string) error {
+				return errFoo
+			},
+		}
+		provider := NewProvider(sessionStorage, func(maxAge int64) AgeChecker {
+			return stubMilliAgeChecker(maxAge)
+		})
+		provider.SetExpiration(500, 0)
+
+		_, err := provider.SessionRead("17af450")
+
+		assert.Equal(t, err, ErrUnableToDestroySession)
+	})
+	t.Run("touches session when idle timeout is set", func(t *testing.T) {
+		touched := 0
+		sessionStorage := &mockSessionStorage{
+			GetSessionFunc: func(sid string) (Session, error) {
+				return newStubSession(sid), nil
+			},
+			TouchSessionFunc: func(sid string) error {
+				touched++
+				return nil
+			},
+		}
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
+
+		provider.SessionRead("17af454")
+		assert.Equal(t, touched, 0)
+
+		provider.SetExpiration(3600, 600)
+		provider.SessionRead("17af454")
+		assert.Equal(t, touched, 1)
+	})
 	t.Run("returns error on failing session restoration", func(t *testing.T) {
 		sessionStorage := &stubFailingSessionStorage{}
-		provider := &defaultProvider{sessionStorage, dummyAdapter}
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
 
 		_, err := provider.SessionRead("17af454")
 
@@ -147,7 +275,7 @@ func TestSessionDestroy(t *testing.T) {
 		},
 	}
 
-	provider := &defaultProvider{sessionStorage, dummyAdapter}
+	provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
 
 	t.Run("destroys session", func(t *testing.T) {
 		sid := "17af454"
@@ -161,7 +289,7 @@ func TestSessionDestroy(t *testing.T) {
 	})
 	t.Run("returns error for destroy failing", func(t *testing.T) {
 		sessionStorage := &stubFailingSessionStorage{}
-		provider := &defaultProvider{sessionStorage, dummyAdapter}
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
 
 		err := provider.SessionDestroy("17af454")
 
@@ -169,6 +297,61 @@ func TestSessionDestroy(t *testing.T) {
 	})
 }
 
+func TestSessionRegenerate(t *testing.T) {
+
+	sessionStorage := &stubSessionStorage{
+		Sessions: map[string]*stubSession{
+			"17af454": {
+				Id: "17af454",
+				V:  map[string]any{"foo": "bar"},
+			},
+		},
+	}
+
+	provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
+
+	t.Run("moves session to new id", func(t *testing.T) {
+		session, err := provider.SessionRegenerate("17af454", "17af450")
+
+		assert.NoError(t, err)
+		assert.NotNil(t, session)
+		assert.Equal(t, session.SessionID(), "17af450")
+		assert.Equal(t, session.Get("foo"), any("bar"))
+
+		if _, ok := sessionStorage.Sessions["17af454"]; ok {
+			t.Error("didn't remove old session")
+		}
+	})
+	t.Run("start new session if has no session to move", func(t *testing.T) {
+		session, err := provider.SessionRegenerate("17af000", "17af001")
+
+		assert.NoError(t, err)
+		assert.NotNil(t, session)
+		assert.Equal(t, session.SessionID(), "17af001")
+	})
+	t.Run("returns error for empty sid", func(t *testing.T) {
+		_, err := provider.SessionRegenerate("17af450", "")
+
+		assert.Error(t, err, ErrEmptySessionId)
+	})
+	t.Run("returns error for duplicated sid", func(t *testing.T) {
+		_, err := provider.SessionRegenerate("17af450", "17af001")
+
+		assert.Error(t, err, ErrDuplicatedSessionId)
+	})
+	t.Run("returns error for storage failure", func(t *testing.T) {
+		sessionStorage := &mockSessionStorage{
+			ContainsSessionFunc: func(sid string) (bool, error) { return false, nil },
+			RegenerateFunc:      func(oldSid, newSid string) (Session, error) { return nil, errFoo },
+		}
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: dummyAdapter}
+
+		_, err := provider.SessionRegenerate("17af450", "17af451")
+
+		assert.Error(t, err, ErrUnableToRegenerateSession)
+	})
+}
+
 func TestSessionGC(t *testing.T) {
 
 	t.Run("destroy sessions that arrives max age", func(t *testing.T) {
@@ -180,9 +363,9 @@ func TestSessionGC(t *testing.T) {
 			Sessions: map[string]*stubSession{},
 		}
 
-		provider := &defaultProvider{sessionStorage, func(maxAge int64) AgeChecker {
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: func(maxAge int64) AgeChecker {
 			return stubMilliAgeChecker(maxAge)
-		}}
+		}, maxAge: 1}
 
 		sessionStorage.Sessions[sid1] = newStubSession(sid1)
 
@@ -190,7 +373,10 @@ func TestSessionGC(t *testing.T) {
 
 		sessionStorage.Sessions[sid2] = newStubSession(sid2)
 
-		provider.SessionGC(1)
+		reaped, err := provider.SessionGC()
+
+		assert.NoError(t, err)
+		assert.Equal(t, reaped, 1)
 
 		if _, ok := sessionStorage.Sessions[sid1]; ok {
 			t.Fatal("didn't destroy session")
@@ -200,4 +386,240 @@ func TestSessionGC(t *testing.T) {
 			t.Errorf("expected the session with id=%s in storage", sid2)
 		}
 	})
+	t.Run("destroy sessions that arrives idle timeout", func(t *testing.T) {
+		sessionStorage := &stubSessionStorage{
+			Sessions: map[string]*stubSession{},
+		}
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: func(maxAge int64) AgeChecker {
+			return stubMilliAgeChecker(maxAge)
+		}}
+		provider.SetExpiration(5000, 500)
+
+		idle := newStubSession("17af450")
+		idle.AccessedAt = idle.AccessedAt.Add(-time.Second)
+		sessionStorage.Sessions[idle.Id] = idle
+		sessionStorage.Sessions["17af454"] = newStubSession("17af454")
+
+		reaped, err := provider.SessionGC()
+
+		assert.NoError(t, err)
+		assert.Equal(t, reaped, 1)
+		if _, ok := sessionStorage.Sessions[idle.Id]; ok {
+			t.Fatal("didn't destroy idle session")
+		}
+	})
+	t.Run("runs while the expiration is set", func(t *testing.T) {
+		provider := &defaultProvider{storage: newStubSessionStorage(), ageCheckerAdapter: dummyAdapter}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range 100 {
+				provider.SessionGC()
+			}
+		}()
+		for i := range 100 {
+			provider.SetExpiration(3600, int64(i))
+		}
+		<-done
+	})
+	t.Run("returns error when fail to destroy sessions", func(t *testing.T) {
+		provider := &defaultProvider{storage: &stubFailingSessionStorage{}, ageCheckerAdapter: dummyAdapter}
+
+		_, err := provider.SessionGC()
+
+		if !errors.Is(err, ErrUnableToDestroySession) || !errors.Is(err, errFoo) {
+			t.Errorf("expected the storage error, got %v", err)
+		}
+	})
+}
+
+func TestSessionBindUser(t *testing.T) {
+	t.Run("binds session through storage", func(t *testing.T) {
+		sessionStorage := newStubSessionStorage()
+		sessionStorage.CreateSession("17af450")
+		provider := NewProvider(sessionStorage, nil)
+
+		_, err := provider.SessionBindUser("17af450", "alex", UserLimit{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, sessionStorage.Sessions["17af450"].User, "alex")
+	})
+	t.Run("returns error for unknown session", func(t *testing.T) {
+		provider := NewProvider(newStubSessionStorage(), nil)
+
+		_, err := provider.SessionBindUser("17af450", "alex", UserLimit{})
+
+		if !errors.Is(err, ErrUnableToSaveSession) || !errors.Is(err, ErrUnknownSessionId) {
+			t.Errorf("didn't wrap the storage error, got %v", err)
+		}
+	})
+	t.Run("returns error for unsupported storage", func(t *testing.T) {
+		provider := NewProvider(&mockSessionStorage{
+			BindUserFunc: func(sid, uid string, limit UserLimit) ([]string, error) {
+				return nil, ErrNotSupported
+			},
+		}, nil)
+
+		_, err := provider.SessionBindUser("17af450", "alex", UserLimit{})
+
+		if !errors.Is(err, ErrNotSupported) {
+			t.Errorf("didn't wrap the storage error, got %v", err)
+		}
+	})
+}
+
+func TestSessionBindUserLimit(t *testing.T) {
+	sessionStorage := newStubSessionStorage()
+	provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: func(maxAge int64) AgeChecker {
+		return stubMilliAgeChecker(maxAge)
+	}, maxAge: 500}
+	for _, sid := range []string{"17af450", "17af454", "17af458"} {
+		sessionStorage.CreateSession(sid)
+	}
+	sessionStorage.BindUser("17af450", "alex", UserLimit{})
+	sessionStorage.BindUser("17af454", "alex", UserLimit{})
+	sessionStorage.Sessions["17af450"].CreatedAt = time.Now().Add(-time.Second)
+
+	t.Run("doesn't count expired sessions", func(t *testing.T) {
+		evicted, err := provider.SessionBindUser("17af458", "alex", UserLimit{Max: 2})
+
+		assert.NoError(t, err)
+		assert.Equal(t, len(evicted), 0)
+		if _, ok := sessionStorage.Sessions["17af450"]; ok {
+			t.Error("didn't remove expired session")
+		}
+	})
+	t.Run("returns limit error unwrapped", func(t *testing.T) {
+		sessionStorage.CreateSession("17af45c")
+
+		_, err := provider.SessionBindUser("17af45c", "alex", UserLimit{Max: 2})
+
+		var limitErr *SessionLimitError
+		if !errors.As(err, &limitErr) || errors.Is(err, ErrUnableToSaveSession) {
+			t.Errorf("didn't return limit error, got %v", err)
+		}
+	})
+}
+
+func TestUserSessions(t *testing.T) {
+	sessionStorage := newStubSessionStorage()
+	provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: func(maxAge int64) AgeChecker {
+		return stubMilliAgeChecker(maxAge)
+	}, maxAge: 500}
+	for _, sid := range []string{"17af450", "17af454", "17af458"} {
+		sessionStorage.CreateSession(sid)
+		sessionStorage.BindUser(sid, "alex", UserLimit{})
+	}
+	sessionStorage.Sessions["17af454"].CreatedAt = time.Now().Add(-time.Second)
+
+	t.Run("returns active user sessions", func(t *testing.T) {
+		got, err := provider.UserSessions("alex")
+
+		assert.NoError(t, err)
+		assert.Equal(t, got, []string{"17af450", "17af458"})
+	})
+	t.Run("returns error when storage fails", func(t *testing.T) {
+		provider := NewProvider(&stubFailingSessionStorage{}, nil)
+
+		_, err := provider.UserSessions("alex")
+
+		if !errors.Is(err, ErrUnableToRestoreSession) || !errors.Is(err, errFoo) {
+			t.Errorf("didn't wrap the storage error, got %v", err)
+		}
+	})
+}
+
+func TestProviderEvents(t *testing.T) {
+	newProvider := func() (*defaultProvider, *stubSessionStorage, *[]Event) {
+		sessionStorage := newStubSessionStorage()
+		provider := &defaultProvider{storage: sessionStorage, ageCheckerAdapter: func(maxAge int64) AgeChecker {
+			return stubMilliAgeChecker(maxAge)
+		}}
+		provider.SetExpiration(5000, 500)
+		var events []Event
+		provider.Subscribe(func(e Event) {
+			events = append(events, e)
+		})
+		return provider, sessionStorage, &events
+	}
+
+	t.Run("emits created, read and destroyed events", func(t *testing.T) {
+		provider, _, events := newProvider()
+
+		_, err := provider.SessionInit("17af454")
+		assert.NoError(t, err)
+		_, err = provider.SessionRead("17af454")
+		assert.NoError(t, err)
+		err = provider.SessionDestroy("17af454")
+		assert.NoError(t, err)
+
+		want := []EventType{EventCreated, EventRead, EventDestroyed}
+		if len(*events) != len(want) {
+			t.Fatalf("expected %d events, got %+v", len(want), *events)
+		}
+		for i, e := range *events {
+			assert.Equal(t, e.Type, want[i])
+			assert.Equal(t, e.SID, "17af454")
+		}
+		if (*events)[0].Created.IsZero() {
+			t.Error("didn't set the session creation time")
+		}
+		if (*events)[2].Created.IsZero() || (*events)[2].Accessed.IsZero() {
+			t.Error("didn't set the destroyed session timestamps")
+		}
+	})
+	t.Run("emits expired event with the reason", func(t *testing.T) {
+		provider, sessionStorage, events := newProvider()
+		idle := newStubSession("17af454")
+		idle.AccessedAt = idle.AccessedAt.Add(-time.Second)
+		sessionStorage.Sessions[idle.Id] = idle
+
+		_, err := provider.SessionRead(idle.Id)
+		assert.NoError(t, err)
+
+		if len(*events) == 0 {
+			t.Fatal("didn't emit events")
+		}
+		e := (*events)[0]
+		assert.Equal(t, e.Type, EventExpired)
+		assert.Equal(t, e.SID, idle.Id)
+		assert.Equal(t, e.Reason, "idle timeout")
+	})
+	t.Run("emits regenerated event with the old id", func(t *testing.T) {
+		provider, sessionStorage, events := newProvider()
+		sessionStorage.Sessions["17af454"] = newStubSession("17af454")
+
+		_, err := provider.SessionRegenerate("17af454", "17af455")
+		assert.NoError(t, err)
+
+		if len(*events) != 1 {
+			t.Fatalf("expected one event, got %+v", *events)
+		}
+		e := (*events)[0]
+		assert.Equal(t, e.Type, EventRegenerated)
+		assert.Equal(t, e.SID, "17af455")
+		assert.Equal(t, e.OldSID, "17af454")
+	})
+	t.Run("emits expired event for sessions removed by the gc", func(t *testing.T) {
+		provider, sessionStorage, events := newProvider()
+		idle := newStubSession("17af454")
+		idle.AccessedAt = idle.AccessedAt.Add(-time.Second)
+		idle.User = "alex"
+		sessionStorage.Sessions[idle.Id] = idle
+		sessionStorage.Sessions["17af455"] = newStubSession("17af455")
+
+		_, err := provider.SessionGC()
+		assert.NoError(t, err)
+
+		if len(*events) != 1 {
+			t.Fatalf("expected one event, got %+v", *events)
+		}
+		assert.Equal(t, (*events)[0].Type, EventExpired)
+		assert.Equal(t, (*events)[0].SID, idle.Id)
+		assert.Equal(t, (*events)[0].Created, idle.CreatedAt)
+		assert.Equal(t, (*events)[0].Accessed, idle.AccessedAt)
+		assert.Equal(t, (*events)[0].UID, "alex")
+		assert.Equal(t, (*events)[0].Reason, LifetimeIdleTimeout)
+	})
 }
@@ -0,0 +1,87 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var ErrShortSigningKey error = errors.New("session: signing key must have at least 32 bytes")
+
+const signatureSep = "."
+
+// Signs the session identifiers with HMAC-SHA256. The first key is used
+// to sign, while all of them are used to verify.
+type signer struct {
+	keys [][]byte
+}
+
+func (s *signer) mac(key []byte, sid string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(sid))
+	return h.Sum(nil)
+}
+
+func (s *signer) sign(sid string) string {
+	return sid + signatureSep + base64.RawURLEncoding.EncodeToString(s.mac(s.keys[0], sid))
+}
+
+func (s *signer) verify(value string) (string, bool) {
+	i := strings.LastIndex(value, signatureSep)
+	if i < 0 {
+		return "", false
+	}
+	sid := value[:i]
+	sig, err := base64.RawURLEncoding.DecodeString(value[i+len(signatureSep):])
+	if err != nil {
+		return "", false
+	}
+	for _, key := range s.keys {
+		if hmac.Equal(sig, s.mac(key, sid)) {
+			return sid, true
+		}
+	}
+	return "", false
+}
+
+// Sets the keys to sign the session identifier sent to the client, so
+// a forged or tampered one is rejected before reaching the provider.
+//
+// The first key signs the new identifiers, the remaining ones are only
+// used to verify. To rotate, add the new key at the beginning and drop
+// the oldest one once the identifiers signed with it have expired.
+// Calling without keys disables the signing, which is the default.
+//
+// Returns ErrShortSigningKey if some key has less than 32 bytes.
+func (m *Manager) SetSigningKeys(keys ...[]byte) error {
+	var s *signer
+	if len(keys) > 0 {
+		s = &signer{make([][]byte, len(keys))}
+		for i, key := range keys {
+			if len(key) < 32 {
+				return ErrShortSigningKey
+			}
+			s.keys[i] = append([]byte(nil), key...)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signer = s
+	return nil
+}
+
+func (m *Manager) signID(sid string) string {
+	if m.signer == nil {
+		return sid
+	}
+	return m.signer.sign(sid)
+}
+
+func (m *Manager) verifyID(value string) (string, bool) {
+	if m.signer == nil {
+		return value, true
+	}
+	return m.signer.verify(value)
+}
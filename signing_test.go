@@ -0,0 +1,105 @@
+package session
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestSigner(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	t.Run("verifies signed id", func(t *testing.T) {
+		s := &signer{[][]byte{newKey}}
+
+		sid, ok := s.verify(s.sign("node.abcde"))
+
+		assert.Equal(t, ok, true)
+		assert.Equal(t, sid, "node.abcde")
+	})
+	t.Run("verifies id signed with old key", func(t *testing.T) {
+		old := &signer{[][]byte{oldKey}}
+		s := &signer{[][]byte{newKey, oldKey}}
+
+		sid, ok := s.verify(old.sign("abcde"))
+
+		assert.Equal(t, ok, true)
+		assert.Equal(t, sid, "abcde")
+	})
+
+	s := &signer{[][]byte{newKey}}
+	signed := s.sign("abcde")
+	cases := []struct {
+		desc  string
+		value string
+	}{
+		{"unsigned", "abcde"},
+		{"tampered id", "abcdf" + signed[len("abcde"):]},
+		{"tampered signature", signed[:len(signed)-1] + "A"},
+		{"signed with unknown key", (&signer{[][]byte{oldKey}}).sign("abcde")},
+	}
+	for _, c := range cases {
+		t.Run("rejects "+c.desc, func(t *testing.T) {
+			_, ok := s.verify(c.value)
+
+			assert.Equal(t, ok, false)
+		})
+	}
+}
+
+func TestManager_SetSigningKeys(t *testing.T) {
+	provider := &stubProvider{}
+	manager := NewManager(provider, "SessionID", 3600)
+	err := manager.SetSigningKeys(bytes.Repeat([]byte{1}, 32))
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+	res := httptest.NewRecorder()
+
+	session := manager.StartSession(res, req)
+	cookie := res.Result().Cookies()[0]
+
+	t.Run("signs the session id", func(t *testing.T) {
+		value, _ := url.QueryUnescape(cookie.Value)
+		if value == session.SessionID() {
+			t.Fatal("didn't sign the session id")
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(cookie)
+		res := httptest.NewRecorder()
+
+		got := manager.StartSession(res, req)
+
+		assert.Equal(t, got.SessionID(), session.SessionID())
+	})
+	t.Run("rejects unsigned session id", func(t *testing.T) {
+		var events []Event
+		manager.OnReject(func(e Event) {
+			events = append(events, e)
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(&http.Cookie{Name: "SessionID", Value: url.QueryEscape(session.SessionID())})
+		res := httptest.NewRecorder()
+
+		got := manager.StartSession(res, req)
+
+		if got.SessionID() == session.SessionID() {
+			t.Error("didn't reject unsigned session id")
+		}
+		if len(events) != 1 || events[0].Reason != "invalid signature" {
+			t.Errorf("didn't raise rejected event, got %+v", events)
+		}
+	})
+	t.Run("returns error for short key", func(t *testing.T) {
+		err := manager.SetSigningKeys(bytes.Repeat([]byte{1}, 32), []byte("short"))
+
+		assert.Equal(t, err, ErrShortSigningKey)
+	})
+}
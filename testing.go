@@ -3,21 +3,27 @@ package session
 import (
 	"errors"
 	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
 
 type stubSession struct {
-	Id        string
-	CreatedAt time.Time
-	V         map[string]any
+	Id         string
+	CreatedAt  time.Time
+	AccessedAt time.Time
+	User       string
+	V          map[string]any
 }
 
 func newStubSession(id string) *stubSession {
+	now := time.Now()
 	return &stubSession{
-		Id:        id,
-		CreatedAt: time.Now(),
-		V:         map[string]any{},
+		Id:         id,
+		CreatedAt:  now,
+		AccessedAt: now,
+		V:          map[string]any{},
 	}
 }
 
@@ -39,6 +45,10 @@ func (s *stubSession) Values() map[string]any {
 	return maps.Clone(s.V)
 }
 
+func (s *stubSession) Keys() []string {
+	return slices.Sorted(maps.Keys(s.V))
+}
+
 func (s *stubSession) SessionID() string {
 	return s.Id
 }
@@ -47,9 +57,15 @@ func (s *stubSession) CreationTime() time.Time {
 	return s.CreatedAt
 }
 
+func (s *stubSession) AccessTime() time.Time {
+	return s.AccessedAt
+}
+
 type stubProvider struct {
 	mu       sync.Mutex
 	Sessions map[string]Session
+	MaxAge   int64
+	Idle     int64
 }
 
 func (p *stubProvider) SessionInit(sid string) (Session, error) {
@@ -58,15 +74,18 @@ func (p *stubProvider) SessionInit(sid string) (Session, error) {
 	if p.Sessions == nil {
 		p.Sessions = make(map[string]Session)
 	}
-	sess := &stubSession{
-		Id: sid,
-	}
+	sess := newStubSession(sid)
 	p.Sessions[sid] = sess
 	return sess, nil
 }
 
 func (p *stubProvider) SessionRead(sid string) (Session, error) {
-	sess := p.Sessions[sid]
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sess, ok := p.Sessions[sid]
+	if !ok {
+		return nil, ErrUnknownSessionId
+	}
 	return sess, nil
 }
 
@@ -77,7 +96,62 @@ func (p *stubProvider) SessionDestroy(sid string) error {
 	return nil
 }
 
-func (p *stubProvider) SessionGC(maxLifeTime int64) {}
+func (p *stubProvider) SessionRegenerate(oldSid, newSid string) (Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old, ok := p.Sessions[oldSid]
+	if !ok {
+		return nil, nil
+	}
+	sess := &stubSession{
+		Id:   newSid,
+		User: old.(*stubSession).User,
+		V:    old.(*stubSession).Values(),
+	}
+	delete(p.Sessions, oldSid)
+	p.Sessions[newSid] = sess
+	return sess, nil
+}
+
+func (p *stubProvider) SetExpiration(maxAge, idle int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.MaxAge = maxAge
+	p.Idle = idle
+}
+
+func (p *stubProvider) SessionGC() (int, error) {
+	return 0, nil
+}
+
+func (p *stubProvider) SessionBindUser(sid, uid string, limit UserLimit) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sess, ok := p.Sessions[sid]
+	if !ok {
+		return nil, ErrUnknownSessionId
+	}
+	sess.(*stubSession).User = uid
+	return nil, nil
+}
+
+func (p *stubProvider) UserSessions(uid string) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return userSessions(p.Sessions, uid), nil
+}
+
+// Returns the sorted identifiers of the stub sessions bound to the user.
+func userSessions[S Session](sessions map[string]S, uid string) []string {
+	var sids []string
+	for sid, sess := range sessions {
+		if any(sess).(*stubSession).User == uid {
+			sids = append(sids, sid)
+		}
+	}
+	slices.Sort(sids)
+	return sids
+}
 
 type stubFailingProvider struct{}
 
@@ -93,7 +167,23 @@ func (p *stubFailingProvider) SessionDestroy(sid string) error {
 	return errFoo
 }
 
-func (p *stubFailingProvider) SessionGC(maxLifeTime int64) {}
+func (p *stubFailingProvider) SessionRegenerate(oldSid, newSid string) (Session, error) {
+	return nil, errFoo
+}
+
+func (p *stubFailingProvider) SetExpiration(maxAge, idle int64) {}
+
+func (p *stubFailingProvider) SessionGC() (int, error) {
+	return 0, errFoo
+}
+
+func (p *stubFailingProvider) SessionBindUser(sid, uid string, limit UserLimit) ([]string, error) {
+	return nil, errFoo
+}
+
+func (p *stubFailingProvider) UserSessions(uid string) ([]string, error) {
+	return nil, errFoo
+}
 
 type stubSessionStorage struct {
 	mu       sync.Mutex
@@ -133,14 +223,90 @@ func (ss *stubSessionStorage) ReapSession(sid string) error {
 	return nil
 }
 
-func (ss *stubSessionStorage) Deadline(checker AgeChecker) {
+func (ss *stubSessionStorage) RegenerateSession(oldSid, newSid string) (Session, error) {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
+	old, ok := ss.Sessions[oldSid]
+	if !ok {
+		return nil, nil
+	}
+	sess := &stubSession{
+		Id:        newSid,
+		CreatedAt: old.CreatedAt,
+		User:      old.User,
+		V:         old.Values(),
+	}
+	delete(ss.Sessions, oldSid)
+	ss.Sessions[newSid] = sess
+	return sess, nil
+}
+
+func (ss *stubSessionStorage) TouchSession(sid string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if sess, ok := ss.Sessions[sid]; ok {
+		sess.AccessedAt = time.Now()
+	}
+	return nil
+}
+
+func (ss *stubSessionStorage) Deadline(absolute, idle AgeChecker) ([]ExpiredSession, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	var reaped []ExpiredSession
 	for k, v := range ss.Sessions {
-		if checker.ShouldReap(v.CreatedAt) {
+		if lifetime := ExpiredLifetime(absolute, idle, v.CreatedAt, v.AccessedAt); lifetime != "" {
 			delete(ss.Sessions, k)
+			reaped = append(reaped, ExpiredSession{SessionInfo{k, v.CreatedAt, v.AccessedAt, v.User}, lifetime})
+		}
+	}
+	return reaped, nil
+}
+
+func (ss *stubSessionStorage) BindUser(sid, uid string, limit UserLimit) ([]string, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	sess, ok := ss.Sessions[sid]
+	if !ok {
+		return nil, ErrUnknownSessionId
+	}
+	var evicted []string
+	if held := userSessions(ss.Sessions, uid); limit.Max > 0 && sess.User != uid && len(held) >= limit.Max {
+		if !limit.EvictOldest {
+			return nil, &SessionLimitError{UID: uid, Max: limit.Max}
 		}
+		slices.SortFunc(held, func(a, b string) int {
+			return ss.Sessions[a].CreatedAt.Compare(ss.Sessions[b].CreatedAt)
+		})
+		evicted = held[:len(held)-limit.Max+1]
+		for _, sid := range evicted {
+			delete(ss.Sessions, sid)
+		}
+	}
+	sess.User = uid
+	return evicted, nil
+}
+
+func (ss *stubSessionStorage) UserSessions(uid string) ([]string, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return userSessions(ss.Sessions, uid), nil
+}
+
+func (ss *stubSessionStorage) ListSessions() ([]SessionInfo, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	infos := []SessionInfo{}
+	for _, sess := range ss.Sessions {
+		infos = append(infos, SessionInfo{sess.Id, sess.CreatedAt, sess.AccessedAt, sess.User})
 	}
+	slices.SortFunc(infos, func(a, b SessionInfo) int {
+		if c := a.Created.Compare(b.Created); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return infos, nil
 }
 
 type spySessionStorage struct {
@@ -148,6 +314,7 @@ type spySessionStorage struct {
 	callsToGetSession      int
 	callsToContainsSession int
 	callsToReapSession     int
+	callsToRegenerate      int
 	callsToDeadline        int
 }
 
@@ -171,8 +338,30 @@ func (ss *spySessionStorage) ReapSession(sid string) error {
 	return nil
 }
 
-func (ss *spySessionStorage) Deadline(checker AgeChecker) {
+func (ss *spySessionStorage) RegenerateSession(oldSid, newSid string) (Session, error) {
+	ss.callsToRegenerate++
+	return nil, nil
+}
+
+func (ss *spySessionStorage) TouchSession(sid string) error {
+	return nil
+}
+
+func (ss *spySessionStorage) Deadline(absolute, idle AgeChecker) ([]ExpiredSession, error) {
 	ss.callsToDeadline++
+	return nil, nil
+}
+
+func (ss *spySessionStorage) BindUser(sid, uid string, limit UserLimit) ([]string, error) {
+	return nil, nil
+}
+
+func (ss *spySessionStorage) UserSessions(uid string) ([]string, error) {
+	return nil, nil
+}
+
+func (ss *spySessionStorage) ListSessions() ([]SessionInfo, error) {
+	return nil, nil
 }
 
 type stubFailingSessionStorage struct {
@@ -197,7 +386,28 @@ func (ss *stubFailingSessionStorage) ReapSession(sid string) error {
 	return errFoo
 }
 
-func (ss *stubFailingSessionStorage) Deadline(checker AgeChecker) {
+func (ss *stubFailingSessionStorage) RegenerateSession(oldSid, newSid string) (Session, error) {
+	return nil, errFoo
+}
+
+func (ss *stubFailingSessionStorage) TouchSession(sid string) error {
+	return errFoo
+}
+
+func (ss *stubFailingSessionStorage) Deadline(absolute, idle AgeChecker) ([]ExpiredSession, error) {
+	return nil, errFoo
+}
+
+func (ss *stubFailingSessionStorage) BindUser(sid, uid string, limit UserLimit) ([]string, error) {
+	return nil, errFoo
+}
+
+func (ss *stubFailingSessionStorage) UserSessions(uid string) ([]string, error) {
+	return nil, errFoo
+}
+
+func (ss *stubFailingSessionStorage) ListSessions() ([]SessionInfo, error) {
+	return nil, errFoo
 }
 
 type mockSessionStorage struct {
@@ -206,7 +416,12 @@ type mockSessionStorage struct {
 	GetSessionFunc      func(sid string) (Session, error)
 	ContainsSessionFunc func(sid string) (bool, error)
 	ReapSessionFunc     func(sid string) error
-	DeadlineFunc        func(checker AgeChecker)
+	RegenerateFunc      func(oldSid, newSid string) (Session, error)
+	TouchSessionFunc    func(sid string) error
+	DeadlineFunc        func(absolute, idle AgeChecker) ([]ExpiredSession, error)
+	BindUserFunc        func(sid, uid string, limit UserLimit) ([]string, error)
+	UserSessionsFunc    func(uid string) ([]string, error)
+	ListSessionsFunc    func() ([]SessionInfo, error)
 }
 
 func (ss *mockSessionStorage) CreateSession(sid string) (Session, error) {
@@ -225,8 +440,28 @@ func (ss *mockSessionStorage) ReapSession(sid string) error {
 	return ss.ReapSessionFunc(sid)
 }
 
-func (ss *mockSessionStorage) Deadline(checker AgeChecker) {
-	ss.DeadlineFunc(checker)
+func (ss *mockSessionStorage) RegenerateSession(oldSid, newSid string) (Session, error) {
+	return ss.RegenerateFunc(oldSid, newSid)
+}
+
+func (ss *mockSessionStorage) TouchSession(sid string) error {
+	return ss.TouchSessionFunc(sid)
+}
+
+func (ss *mockSessionStorage) Deadline(absolute, idle AgeChecker) ([]ExpiredSession, error) {
+	return ss.DeadlineFunc(absolute, idle)
+}
+
+func (ss *mockSessionStorage) BindUser(sid, uid string, limit UserLimit) ([]string, error) {
+	return ss.BindUserFunc(sid, uid, limit)
+}
+
+func (ss *mockSessionStorage) UserSessions(uid string) ([]string, error) {
+	return ss.UserSessionsFunc(uid)
+}
+
+func (ss *mockSessionStorage) ListSessions() ([]SessionInfo, error) {
+	return ss.ListSessionsFunc()
 }
 
 type stubMilliAgeChecker int64
@@ -235,3 +470,63 @@ func (m stubMilliAgeChecker) ShouldReap(t time.Time) bool {
 	diff := time.Now().UnixMilli() - t.UnixMilli()
 	return diff > int64(m)
 }
+
+// Metrics that records what is reported.
+type spyMetrics struct {
+	mu        sync.Mutex
+	created   int
+	read      int
+	destroyed int
+	expired   []string // reasons
+	live      int
+	gcRuns    int
+	reaped    int
+	ops       []string
+	errs      int
+}
+
+func (m *spyMetrics) SessionCreated() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.created++
+}
+
+func (m *spyMetrics) SessionRead() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.read++
+}
+
+func (m *spyMetrics) SessionDestroyed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.destroyed++
+}
+
+func (m *spyMetrics) SessionExpired(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expired = append(m.expired, reason)
+}
+
+func (m *spyMetrics) LiveSessions(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.live = n
+}
+
+func (m *spyMetrics) GCRun(d time.Duration, reaped int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gcRuns++
+	m.reaped += reaped
+}
+
+func (m *spyMetrics) StorageOperation(op string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ops = append(m.ops, op)
+	if err != nil {
+		m.errs++
+	}
+}
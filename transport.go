@@ -0,0 +1,200 @@
+package session
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Transport carries the session identifier between the client and the
+// Manager. The identifier is signed and validated by the Manager, so the
+// transport only reads and writes it.
+type Transport interface {
+	// Returns the identifier sent by the client, or empty if there is
+	// none.
+	ReadID(r *http.Request) string
+	// Sends the identifier to the client, to be kept for maxAge seconds.
+	WriteID(w http.ResponseWriter, sid string, maxAge int)
+	// Tells the client to discard the identifier.
+	ClearID(w http.ResponseWriter)
+}
+
+type cookieTransport struct {
+	mu   sync.Mutex
+	name string
+	opts CookieOptions
+}
+
+// Returns a transport that carries the identifier in a http cookie with
+// the given name. The attributes are the DefaultCookieOptions(), which
+// are secure when the name has "__Secure-" or "__Host-" prefix. To
+// change them, use SetCookieOptions().
+//
+// It's the Manager default transport.
+func NewCookieTransport(name string) *cookieTransport {
+	if name == "" {
+		panic("empty cookie name")
+	}
+	return &cookieTransport{name: name, opts: defaultCookieOptionsFor(name)}
+}
+
+// Sets the attributes for the http cookie.
+//
+// Returns error if the options cannot be used with the cookie name (see
+// CookieOptions.Validate()).
+func (t *cookieTransport) SetCookieOptions(opts CookieOptions) error {
+	if err := opts.Validate(t.name); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.opts = opts
+	return nil
+}
+
+func (t *cookieTransport) ReadID(r *http.Request) string {
+	cookie, err := r.Cookie(t.name)
+	if err != nil {
+		return ""
+	}
+	sid, _ := url.QueryUnescape(cookie.Value)
+	return sid
+}
+
+func (t *cookieTransport) WriteID(w http.ResponseWriter, sid string, maxAge int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	http.SetCookie(w, t.opts.cookie(t.name, url.QueryEscape(sid), maxAge))
+}
+
+func (t *cookieTransport) ClearID(w http.ResponseWriter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	http.SetCookie(w, t.opts.cookie(t.name, "", -1))
+}
+
+type headerTransport struct {
+	name string
+}
+
+// Returns a transport that carries the identifier in the http header
+// with the given name (e.g. "X-Session-Id"), both in the request and in
+// the response. To discard the identifier, the header is sent empty.
+func NewHeaderTransport(name string) Transport {
+	if name == "" {
+		panic("empty header name")
+	}
+	return &headerTransport{http.CanonicalHeaderKey(name)}
+}
+
+func (t *headerTransport) ReadID(r *http.Request) string {
+	return r.Header.Get(t.name)
+}
+
+func (t *headerTransport) WriteID(w http.ResponseWriter, sid string, maxAge int) {
+	w.Header().Set(t.name, sid)
+}
+
+func (t *headerTransport) ClearID(w http.ResponseWriter) {
+	w.Header().Set(t.name, "")
+}
+
+type bearerTransport struct {
+	headerTransport
+}
+
+// Returns a transport that reads the identifier from the request
+// "Authorization: Bearer" header. Since a response cannot carry it the
+// same way, the identifier is sent to the client in the http header with
+// the given name (e.g. "X-Session-Id").
+func NewBearerTransport(name string) Transport {
+	if name == "" {
+		panic("empty header name")
+	}
+	return &bearerTransport{headerTransport{http.CanonicalHeaderKey(name)}}
+}
+
+func (t *bearerTransport) ReadID(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+type queryTransport struct {
+	name string
+}
+
+// Returns a transport that reads the identifier from the request url
+// query parameter with the given name. It cannot send the identifier to
+// the client, so it's meant to be chained with another transport (see
+// ChainTransports()).
+func NewQueryTransport(name string) Transport {
+	if name == "" {
+		panic("empty parameter name")
+	}
+	return &queryTransport{name}
+}
+
+func (t *queryTransport) ReadID(r *http.Request) string {
+	return r.URL.Query().Get(t.name)
+}
+
+func (t *queryTransport) WriteID(w http.ResponseWriter, sid string, maxAge int) {}
+
+func (t *queryTransport) ClearID(w http.ResponseWriter) {}
+
+type chainTransport []Transport
+
+// Returns a transport that reads the identifier from the first of the
+// transports where the client sent one, and writes it to all of them.
+//
+//	session.ChainTransports(
+//		session.NewCookieTransport("SessionID"),
+//		session.NewBearerTransport("X-Session-Id"),
+//	)
+func ChainTransports(transports ...Transport) Transport {
+	if len(transports) == 0 {
+		panic("no transports")
+	}
+	for _, t := range transports {
+		if t == nil {
+			panic("nil transport")
+		}
+	}
+	return chainTransport(transports)
+}
+
+func (c chainTransport) ReadID(r *http.Request) string {
+	for _, t := range c {
+		if sid := t.ReadID(r); sid != "" {
+			return sid
+		}
+	}
+	return ""
+}
+
+func (c chainTransport) WriteID(w http.ResponseWriter, sid string, maxAge int) {
+	for _, t := range c {
+		t.WriteID(w, sid, maxAge)
+	}
+}
+
+func (c chainTransport) ClearID(w http.ResponseWriter) {
+	for _, t := range c {
+		t.ClearID(w)
+	}
+}
+
+// Sets the transport for the session identifier, replacing the cookie
+// one created by NewManager() (see NewCookieTransport()).
+func (m *Manager) SetTransport(t Transport) {
+	if t == nil {
+		panic("nil transport")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transport = t
+}
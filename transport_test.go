@@ -0,0 +1,165 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestCookieTransport(t *testing.T) {
+	transport := NewCookieTransport("SessionID")
+
+	t.Run("writes and reads the id", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		transport.WriteID(res, "ab+cd=", 3600)
+
+		cookie := res.Result().Cookies()[0]
+		assert.Equal(t, cookie.Name, "SessionID")
+		assert.Equal(t, cookie.MaxAge, 3600)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.AddCookie(cookie)
+
+		assert.Equal(t, transport.ReadID(req), "ab+cd=")
+	})
+	t.Run("clears the id", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		transport.ClearID(res)
+
+		cookie := res.Result().Cookies()[0]
+		assert.Equal(t, cookie.Value, "")
+		if cookie.MaxAge >= 0 {
+			t.Errorf("didn't expire the cookie, got max age %d", cookie.MaxAge)
+		}
+	})
+}
+
+func TestHeaderTransport(t *testing.T) {
+	transport := NewHeaderTransport("x-session-id")
+
+	t.Run("writes and reads the id", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		transport.WriteID(res, "abcde", 3600)
+
+		assert.Equal(t, res.Header().Get("X-Session-Id"), "abcde")
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.Header.Set("X-Session-Id", "abcde")
+
+		assert.Equal(t, transport.ReadID(req), "abcde")
+	})
+	t.Run("clears the id", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		transport.ClearID(res)
+
+		if v, ok := res.Header()["X-Session-Id"]; !ok || v[0] != "" {
+			t.Errorf("expected empty header, got %v", v)
+		}
+	})
+}
+
+func TestBearerTransport(t *testing.T) {
+	transport := NewBearerTransport("X-Session-Id")
+
+	cases := []struct {
+		desc   string
+		header string
+		want   string
+	}{
+		{"reads the bearer token", "Bearer abcde", "abcde"},
+		{"ignores the scheme case", "bearer abcde", "abcde"},
+		{"ignores other schemes", "Basic abcde", ""},
+		{"ignores missing token", "Bearer", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+			req.Header.Set("Authorization", c.header)
+
+			assert.Equal(t, transport.ReadID(req), c.want)
+		})
+	}
+	t.Run("writes the id into the response header", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		transport.WriteID(res, "abcde", 3600)
+
+		assert.Equal(t, res.Header().Get("X-Session-Id"), "abcde")
+	})
+}
+
+func TestQueryTransport(t *testing.T) {
+	transport := NewQueryTransport("sid")
+
+	req, _ := http.NewRequest(http.MethodGet, dummySite+"?sid=ab%2Bcd", nil)
+
+	assert.Equal(t, transport.ReadID(req), "ab+cd")
+}
+
+func TestChainTransports(t *testing.T) {
+	transport := ChainTransports(NewCookieTransport("SessionID"), NewHeaderTransport("X-Session-Id"))
+
+	t.Run("reads from the first transport with id", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.Header.Set("X-Session-Id", "abcde")
+
+		assert.Equal(t, transport.ReadID(req), "abcde")
+
+		req.AddCookie(&http.Cookie{Name: "SessionID", Value: "fghij"})
+
+		assert.Equal(t, transport.ReadID(req), "fghij")
+	})
+	t.Run("writes to all transports", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		transport.WriteID(res, "abcde", 3600)
+
+		assert.Equal(t, res.Header().Get("X-Session-Id"), "abcde")
+		assert.NotNil(t, getCookieFromResponse(res))
+	})
+	t.Run("panic without transports", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if r != "no transports" {
+				t.Errorf("didn't get expected panic, got: %v", r)
+			}
+		}()
+		ChainTransports()
+	})
+}
+
+func TestManager_SetTransport(t *testing.T) {
+	provider := &stubProvider{}
+	manager := NewManager(provider, "SessionID", 3600)
+	manager.SetTransport(NewBearerTransport("X-Session-Id"))
+
+	req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+	res := httptest.NewRecorder()
+
+	session := manager.StartSession(res, req)
+
+	sid := res.Header().Get("X-Session-Id")
+	assert.Equal(t, sid, session.SessionID())
+	if len(res.Header()["Set-Cookie"]) != 0 {
+		t.Error("didn't expect the session cookie")
+	}
+
+	t.Run("retrieves the session from the token", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		req.Header.Set("Authorization", "Bearer "+sid)
+		res := httptest.NewRecorder()
+
+		got := manager.StartSession(res, req)
+
+		assert.Equal(t, got.SessionID(), session.SessionID())
+	})
+	t.Run("panic on nil transport", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if r != "nil transport" {
+				t.Errorf("didn't get expected panic, got: %v", r)
+			}
+		}()
+		manager.SetTransport(nil)
+	})
+}
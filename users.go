@@ -0,0 +1,130 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// UserLimit caps how many sessions a user can hold (see SetUserLimit()).
+type UserLimit struct {
+	// Maximum number of sessions, zero disables the limit.
+	Max int
+	// Removes the oldest sessions to bind a new one, instead of rejecting
+	// it.
+	EvictOldest bool
+}
+
+var ErrSessionLimit error = errors.New("session: user session limit reached")
+
+// SessionLimitError is returned when a session cannot be bound because
+// the user already holds the maximum of sessions. It matches
+// ErrSessionLimit through errors.Is().
+type SessionLimitError struct {
+	UID string
+	Max int
+}
+
+func (e *SessionLimitError) Error() string {
+	return fmt.Sprintf("session: user reached the limit of %d sessions", e.Max)
+}
+
+func (e *SessionLimitError) Is(target error) bool {
+	return target == ErrSessionLimit
+}
+
+// Sets how many sessions a user can hold (see BindUser()). When binding
+// one more, either the oldest sessions are evicted, calling the hooks
+// registered through OnEvict(), or it's rejected with *SessionLimitError
+// (e.g. to refuse the login). The limit is disabled by default.
+func (m *Manager) SetUserLimit(limit UserLimit) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.userLimit = limit
+}
+
+// Binds the session to the user identifier, so it's listed through
+// UserSessions() and revoked through RevokeUserSessions() (e.g. after a
+// password reset). An empty uid unbinds it. It should be called once the
+// user is authenticated, after RegenerateID(). A lazy session (see
+// SetLazy()) is created right away.
+//
+// Returns *SessionLimitError when the user holds the limit of sessions
+// (see SetUserLimit()), or the provider error when the session cannot be
+// bound (e.g. wrapping ErrNotSupported when the storage cannot index the
+// sessions by user).
+func (m *Manager) BindUser(sess Session, uid string) error {
+	if sess == nil {
+		panic("nil session")
+	}
+	if l, ok := sess.(*lazySession); ok {
+		if err := l.create(); err != nil {
+			return err
+		}
+	}
+	m.mu.RLock()
+	limit := m.userLimit
+	m.mu.RUnlock()
+	evicted, err := m.provider.SessionBindUser(sess.SessionID(), uid, limit)
+	for _, sid := range evicted {
+		m.events.emit(Event{Type: EventEvicted, SID: sid, UID: uid, Reason: "session limit"})
+	}
+	return err
+}
+
+// Returns the identifiers of the active sessions bound to the user (see
+// BindUser()).
+func (m *Manager) UserSessions(uid string) ([]string, error) {
+	return m.provider.UserSessions(uid)
+}
+
+// Destroys the session, wherever it's used (e.g. by an administrator),
+// unlike DestroySession() which destroys the request one.
+//
+// Returns the provider error when the session cannot be destroyed.
+func (m *Manager) RevokeSession(sid string) error {
+	return m.revoke(sid, "")
+}
+
+// Destroys the sessions bound to the user, except the given ones (e.g.
+// the current session, to log out everywhere else), returning how many
+// were destroyed.
+//
+// Returns the provider error when the sessions cannot be listed, or the
+// failures to destroy them joined.
+func (m *Manager) RevokeUserSessions(uid string, except ...string) (int, error) {
+	sids, err := m.provider.UserSessions(uid)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	var errs []error
+	for _, sid := range sids {
+		if slices.Contains(except, sid) {
+			continue
+		}
+		if err := m.revoke(sid, uid); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		revoked++
+	}
+	return revoked, errors.Join(errs...)
+}
+
+// Destroys the session through the provider, with the "revoked" reason if
+// it's a Revoker.
+func (m *Manager) revoke(sid, uid string) error {
+	var err error
+	if r, ok := m.provider.(Revoker); ok {
+		err = r.SessionRevoke(sid, uid, "revoked")
+	} else {
+		err = m.provider.SessionDestroy(sid)
+	}
+	if err != nil {
+		m.logger.get().Error("session: unable to revoke the session", sidAttr(sid), "err", err)
+		return err
+	}
+	m.emitLifecycle(Event{Type: EventDestroyed, SID: sid, UID: uid, Reason: "revoked"})
+	return nil
+}
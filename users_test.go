@@ -0,0 +1,175 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+func TestManager_UserSessions(t *testing.T) {
+	provider := &stubProvider{}
+	manager := NewManager(provider, "SessionID", 3600)
+
+	var sids []string
+	for range 3 {
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		sess := manager.StartSession(httptest.NewRecorder(), req)
+		assert.NoError(t, manager.BindUser(sess, "alex"))
+		sids = append(sids, sess.SessionID())
+	}
+	req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+	other := manager.StartSession(httptest.NewRecorder(), req)
+	manager.BindUser(other, "andre")
+
+	t.Run("lists the user sessions", func(t *testing.T) {
+		got, err := manager.UserSessions("alex")
+
+		assert.NoError(t, err)
+		assert.Equal(t, len(got), 3)
+	})
+	t.Run("revokes the user sessions except the current one", func(t *testing.T) {
+		revoked, err := manager.RevokeUserSessions("alex", sids[0])
+
+		assert.NoError(t, err)
+		assert.Equal(t, revoked, 2)
+		got, _ := manager.UserSessions("alex")
+		assert.Equal(t, got, []string{sids[0]})
+		if _, ok := provider.Sessions[other.SessionID()]; !ok {
+			t.Error("revoked session of another user")
+		}
+	})
+	t.Run("revokes a session", func(t *testing.T) {
+		var events []Event
+		manager.OnDestroy(func(e Event) {
+			events = append(events, e)
+		})
+
+		err := manager.RevokeSession(other.SessionID())
+
+		assert.NoError(t, err)
+		if _, ok := provider.Sessions[other.SessionID()]; ok {
+			t.Error("didn't revoke the session")
+		}
+		if len(events) != 1 || events[0].Reason != "revoked" {
+			t.Errorf("didn't raise revoked event, got %+v", events)
+		}
+	})
+	t.Run("revokes with the reason through the default provider", func(t *testing.T) {
+		manager := NewManager(NewProvider(newStubSessionStorage(), nil), "SessionID", 3600)
+		var events []Event
+		manager.OnDestroy(func(e Event) {
+			events = append(events, e)
+		})
+		var sids []string
+		for range 2 {
+			req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+			sess := manager.StartSession(httptest.NewRecorder(), req)
+			assert.NoError(t, manager.BindUser(sess, "alex"))
+			sids = append(sids, sess.SessionID())
+		}
+
+		err := manager.RevokeSession(sids[0])
+		assert.NoError(t, err)
+		_, err = manager.RevokeUserSessions("alex")
+		assert.NoError(t, err)
+
+		if len(events) != 2 {
+			t.Fatalf("expected two events, got %+v", events)
+		}
+		for _, e := range events {
+			assert.Equal(t, e.Reason, "revoked")
+		}
+		assert.Equal(t, events[1].UID, "alex")
+	})
+	t.Run("revokes all the user sessions", func(t *testing.T) {
+		revoked, err := manager.RevokeUserSessions("alex")
+
+		assert.NoError(t, err)
+		assert.Equal(t, revoked, 1)
+		got, _ := manager.UserSessions("alex")
+		assert.Equal(t, len(got), 0)
+	})
+	t.Run("creates lazy session when bound", func(t *testing.T) {
+		manager := NewManager(provider, "SessionID", 3600)
+		manager.SetLazy(true)
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		res := httptest.NewRecorder()
+		sess := manager.StartSession(res, req)
+
+		err := manager.BindUser(sess, "alex")
+
+		assert.NoError(t, err)
+		got, _ := manager.UserSessions("alex")
+		assert.Equal(t, got, []string{sess.SessionID()})
+		assert.Equal(t, len(res.Result().Cookies()), 1)
+	})
+	t.Run("returns provider error", func(t *testing.T) {
+		manager := NewManager(&stubFailingProvider{}, "SessionID", 3600)
+
+		err := manager.BindUser(newStubSession("abcde"), "alex")
+		assert.Equal(t, err, errFoo)
+
+		_, err = manager.RevokeUserSessions("alex")
+		if !errors.Is(err, errFoo) {
+			t.Errorf("didn't return provider error, got %v", err)
+		}
+	})
+}
+
+func TestManager_SetUserLimit(t *testing.T) {
+	newManagerWithUser := func(t *testing.T, limit UserLimit) (*Manager, []Session) {
+		t.Helper()
+		manager := NewManager(NewProvider(newStubSessionStorage(), nil), "SessionID", 3600)
+		var sessions []Session
+		for range 2 {
+			req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+			sess := manager.StartSession(httptest.NewRecorder(), req)
+			assert.NoError(t, manager.BindUser(sess, "alex"))
+			sessions = append(sessions, sess)
+			time.Sleep(time.Millisecond)
+		}
+		manager.SetUserLimit(limit)
+		return manager, sessions
+	}
+
+	t.Run("rejects login over the limit", func(t *testing.T) {
+		manager, _ := newManagerWithUser(t, UserLimit{Max: 2})
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		sess := manager.StartSession(httptest.NewRecorder(), req)
+		err := manager.BindUser(sess, "alex")
+
+		var limitErr *SessionLimitError
+		if !errors.As(err, &limitErr) {
+			t.Fatalf("didn't return limit error, got %v", err)
+		}
+		assert.Equal(t, limitErr.UID, "alex")
+		assert.Equal(t, limitErr.Max, 2)
+		assert.Equal(t, errors.Is(err, ErrSessionLimit), true)
+	})
+	t.Run("evicts the oldest session", func(t *testing.T) {
+		manager, sessions := newManagerWithUser(t, UserLimit{Max: 2, EvictOldest: true})
+		var events []Event
+		manager.OnEvict(func(e Event) {
+			events = append(events, e)
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, dummySite, nil)
+		sess := manager.StartSession(httptest.NewRecorder(), req)
+		err := manager.BindUser(sess, "alex")
+
+		assert.NoError(t, err)
+		if len(events) != 1 {
+			t.Fatalf("expected one evict event, got %d", len(events))
+		}
+		assert.Equal(t, events[0].SID, sessions[0].SessionID())
+		assert.Equal(t, events[0].UID, "alex")
+		got, _ := manager.UserSessions("alex")
+		assert.Equal(t, len(got), 2)
+	})
+}
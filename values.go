@@ -0,0 +1,213 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrValueType error = errors.New("session: value cannot be converted")
+
+// Returns the value for the key as T, and whether the key is defined.
+//
+// Values that aren't a T are converted, so the ones stored as maps and
+// slices (e.g. structs by the filesystem storage) are read back as the
+// struct they came from: map[string]any into structs (by the exported
+// field names) and maps with string keys, []any into slices and arrays,
+// and between numeric kinds when the value doesn't change. A pointer T
+// points to the converted value.
+//
+// Returns ErrValueType if the value cannot be converted.
+func GetAs[T any](sess Session, key string) (T, bool, error) {
+	var t T
+	v := sess.Get(key)
+	if v == nil {
+		return t, false, nil
+	}
+	if t, ok := v.(T); ok {
+		return t, true, nil
+	}
+	dst := reflect.ValueOf(&t).Elem()
+	if err := convert(reflect.ValueOf(v), dst); err != nil {
+		return t, true, fmt.Errorf("%w: key %q: %v", ErrValueType, key, err)
+	}
+	return t, true, nil
+}
+
+// Returns the value for the key as T (see GetAs()), or def if the key
+// isn't defined or its value cannot be converted.
+func GetOr[T any](sess Session, key string, def T) T {
+	t, ok, err := GetAs[T](sess, key)
+	if !ok || err != nil {
+		return def
+	}
+	return t
+}
+
+// Returns the value for the key as T (see GetAs()).
+//
+// Panics if the key isn't defined or its value cannot be converted.
+func MustGet[T any](sess Session, key string) T {
+	t, ok, err := GetAs[T](sess, key)
+	if err != nil {
+		panic(err)
+	}
+	if !ok {
+		panic(fmt.Sprintf("session: undefined key %q", key))
+	}
+	return t
+}
+
+// Converts the value into dst, which must be settable.
+func convert(v, dst reflect.Value) error {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			dst.SetZero()
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		dst.SetZero()
+		return nil
+	}
+	t := dst.Type()
+	if v.Type().AssignableTo(t) {
+		dst.Set(v)
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		p := reflect.New(t.Elem())
+		if err := convert(v, p.Elem()); err != nil {
+			return err
+		}
+		dst.Set(p)
+		return nil
+	case reflect.Interface:
+		if v.Type().Implements(t) {
+			dst.Set(v)
+			return nil
+		}
+	case reflect.Struct:
+		if v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+			return convertStruct(v, dst)
+		}
+	case reflect.Map:
+		if v.Kind() == reflect.Map && t.Key().Kind() == reflect.String && v.Type().Key().Kind() == reflect.String {
+			m := reflect.MakeMapWithSize(t, v.Len())
+			iter := v.MapRange()
+			for iter.Next() {
+				e := reflect.New(t.Elem()).Elem()
+				if err := convert(iter.Value(), e); err != nil {
+					return fmt.Errorf("%s: %v", iter.Key(), err)
+				}
+				m.SetMapIndex(iter.Key().Convert(t.Key()), e)
+			}
+			dst.Set(m)
+			return nil
+		}
+	case reflect.Slice:
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			if v.Kind() == reflect.Slice && v.IsNil() {
+				dst.SetZero()
+				return nil
+			}
+			s := reflect.MakeSlice(t, v.Len(), v.Len())
+			if err := convertElems(v, s); err != nil {
+				return err
+			}
+			dst.Set(s)
+			return nil
+		}
+	case reflect.Array:
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Len() == t.Len() {
+			return convertElems(v, dst)
+		}
+	}
+	if v.Kind() == t.Kind() && v.Type().ConvertibleTo(t) {
+		dst.Set(v.Convert(t))
+		return nil
+	}
+	if isNumber(v.Kind()) && isNumber(t.Kind()) {
+		// Only when it converts back to the same value, so it isn't
+		// truncated or overflowed.
+		c := v.Convert(t)
+		if c.Convert(v.Type()).Equal(v) && isNegative(v) == isNegative(c) {
+			dst.Set(c)
+			return nil
+		}
+		return fmt.Errorf("%v overflows %s", v, t)
+	}
+	return fmt.Errorf("cannot convert %s into %s", v.Type(), t)
+}
+
+// Converts the map into the dst struct, setting the exported fields by
+// their names. Fields without a key are left unchanged.
+func convertStruct(m, dst reflect.Value) error {
+	for _, f := range reflect.VisibleFields(dst.Type()) {
+		if !f.IsExported() {
+			continue
+		}
+		v := m.MapIndex(reflect.ValueOf(f.Name).Convert(m.Type().Key()))
+		if !v.IsValid() {
+			continue
+		}
+		field := fieldByIndex(dst, f.Index)
+		if !field.IsValid() {
+			continue
+		}
+		if err := convert(v, field); err != nil {
+			return fmt.Errorf("%s: %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// Returns the nested field, allocating the embedded struct pointers on
+// the way. Returns the zero Value if a pointer cannot be allocated (i.e.
+// it's unexported).
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func convertElems(v, dst reflect.Value) error {
+	for i := range v.Len() {
+		if err := convert(v.Index(i), dst.Index(i)); err != nil {
+			return fmt.Errorf("[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func isNegative(v reflect.Value) bool {
+	switch {
+	case v.CanInt():
+		return v.Int() < 0
+	case v.CanFloat():
+		return v.Float() < 0
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/xandalm/go-session/testing/assert"
+)
+
+type dummyAddress struct {
+	City string
+}
+
+type dummyBase struct {
+	Id int
+}
+
+type dummyUser struct {
+	dummyBase
+	Name      string
+	Roles     []string
+	Addresses []dummyAddress
+	Manager   *dummyAddress
+	Scores    map[string]int
+	age       int
+}
+
+func TestGetAs(t *testing.T) {
+	sess := newStubSession("abcde")
+	sess.Set("logged", true)
+	sess.Set("count", 3)
+	sess.Set("big", int64(1<<40))
+	sess.Set("negative", -1)
+	sess.Set("user", map[string]any{
+		"Id":        1,
+		"Name":      "foo",
+		"Roles":     []string{"admin"},
+		"Addresses": []any{map[string]any{"City": "bar"}},
+		"Manager":   map[string]any{"City": "baz"},
+		"Scores":    map[string]any{"a": 1},
+	})
+
+	t.Run("returns the value of the type", func(t *testing.T) {
+		got, ok, err := GetAs[bool](sess, "logged")
+
+		assert.NoError(t, err)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, got, true)
+	})
+	t.Run("returns not ok for undefined key", func(t *testing.T) {
+		got, ok, err := GetAs[int](sess, "foo")
+
+		assert.NoError(t, err)
+		assert.Equal(t, ok, false)
+		assert.Equal(t, got, 0)
+	})
+	t.Run("converts numbers", func(t *testing.T) {
+		got, _, err := GetAs[int64](sess, "count")
+
+		assert.NoError(t, err)
+		assert.Equal(t, got, 3)
+	})
+	t.Run("converts maps into structs", func(t *testing.T) {
+		got, ok, err := GetAs[dummyUser](sess, "user")
+
+		assert.NoError(t, err)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, got, dummyUser{
+			dummyBase: dummyBase{1},
+			Name:      "foo",
+			Roles:     []string{"admin"},
+			Addresses: []dummyAddress{{"bar"}},
+			Manager:   &dummyAddress{"baz"},
+			Scores:    map[string]int{"a": 1},
+		})
+	})
+	t.Run("converts into pointers", func(t *testing.T) {
+		got, _, err := GetAs[*dummyUser](sess, "user")
+
+		assert.NoError(t, err)
+		assert.Equal(t, got.Name, "foo")
+	})
+	t.Run("returns error for wrong type", func(t *testing.T) {
+		_, ok, err := GetAs[string](sess, "count")
+
+		assert.Equal(t, ok, true)
+		assert.Equal(t, errors.Is(err, ErrValueType), true)
+	})
+	t.Run("returns error for overflow", func(t *testing.T) {
+		_, _, err := GetAs[int8](sess, "big")
+
+		assert.Equal(t, errors.Is(err, ErrValueType), true)
+
+		_, _, err = GetAs[uint](sess, "negative")
+
+		assert.Equal(t, errors.Is(err, ErrValueType), true)
+	})
+	t.Run("returns error for wrong field type", func(t *testing.T) {
+		_, _, err := GetAs[struct{ Name int }](sess, "user")
+
+		assert.Equal(t, errors.Is(err, ErrValueType), true)
+	})
+}
+
+func TestGetOr(t *testing.T) {
+	sess := newStubSession("abcde")
+	sess.Set("count", 3)
+
+	assert.Equal(t, GetOr(sess, "count", 1), 3)
+	assert.Equal(t, GetOr(sess, "foo", 1), 1)
+	assert.Equal(t, GetOr(sess, "count", "bar"), "bar")
+}
+
+func TestMustGet(t *testing.T) {
+	sess := newStubSession("abcde")
+	sess.Set("count", 3)
+
+	assert.Equal(t, MustGet[int](sess, "count"), 3)
+
+	t.Run("panics for undefined key", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if r != `session: undefined key "foo"` {
+				t.Errorf("didn't get expected panic, got %v", r)
+			}
+		}()
+		MustGet[int](sess, "foo")
+	})
+	t.Run("panics for wrong type", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if err, ok := r.(error); !ok || !errors.Is(err, ErrValueType) {
+				t.Errorf("didn't get expected panic, got %v", r)
+			}
+		}()
+		MustGet[string](sess, "count")
+	})
+}